@@ -0,0 +1,178 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"networkmonitor/shared"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxAssertionBodySize caps how much of a response body is read for assertions
+const maxAssertionBodySize = 1 << 20
+
+// needsBody reports whether the assertions inspect the response body
+func needsBody(assertions *shared.Assertions) bool {
+	return assertions != nil &&
+		(assertions.BodyContains != "" || assertions.BodyRegex != "" || len(assertions.JSONPath) > 0)
+}
+
+// evaluateAssertions checks a response against a target's assertions, stopping at the first failure.
+// Checks run in a fixed order, headers and JSON paths sorted by name, so the same response always
+// reports the same failure. totalTime should include reading the body.
+func evaluateAssertions(assertions *shared.Assertions, resp *http.Response, body []byte, totalTime int64) *shared.AssertionResult {
+	fail := func(format string, args ...interface{}) *shared.AssertionResult {
+		return &shared.AssertionResult{Passed: false, Failure: fmt.Sprintf(format, args...)}
+	}
+
+	if len(assertions.StatusCodes) > 0 {
+		matched := false
+		for _, pattern := range assertions.StatusCodes {
+			ok, err := matchStatus(pattern, resp.StatusCode)
+			if err != nil {
+				return fail("invalid status pattern %q: %v", pattern, err)
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("status %d not in %s", resp.StatusCode, strings.Join(assertions.StatusCodes, ", "))
+		}
+	}
+
+	headerNames := make([]string, 0, len(assertions.Headers))
+	for name := range assertions.Headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	for _, name := range headerNames {
+		want := assertions.Headers[name]
+		values, found := resp.Header[http.CanonicalHeaderKey(name)]
+		if !found {
+			return fail("header %s missing", name)
+		}
+		if want != "" && !containsString(values, want) {
+			return fail("header %s is %q, expected %q", name, strings.Join(values, ", "), want)
+		}
+	}
+
+	if assertions.BodyContains != "" && !strings.Contains(string(body), assertions.BodyContains) {
+		return fail("body does not contain %q", assertions.BodyContains)
+	}
+
+	if assertions.BodyRegex != "" {
+		re, err := regexp.Compile(assertions.BodyRegex)
+		if err != nil {
+			return fail("invalid body regex %q: %v", assertions.BodyRegex, err)
+		}
+		if !re.Match(body) {
+			return fail("body does not match %q", assertions.BodyRegex)
+		}
+	}
+
+	if len(assertions.JSONPath) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fail("body is not valid JSON: %v", err)
+		}
+		paths := make([]string, 0, len(assertions.JSONPath))
+		for path := range assertions.JSONPath {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			want := assertions.JSONPath[path]
+			got, found := lookupJSONPath(doc, path)
+			if !found {
+				return fail("JSON path %s not found", path)
+			}
+			if !reflect.DeepEqual(got, want) {
+				return fail("JSON path %s is %v, expected %v", path, got, want)
+			}
+		}
+	}
+
+	if assertions.MaxTotalTime > 0 && totalTime > assertions.MaxTotalTime {
+		return fail("total time %dms exceeds %dms", totalTime, assertions.MaxTotalTime)
+	}
+
+	return &shared.AssertionResult{Passed: true}
+}
+
+// matchStatus reports whether code matches an exact ("200"), class ("2xx") or range ("200-299") pattern
+func matchStatus(pattern string, code int) (bool, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if len(pattern) == 3 && strings.HasSuffix(pattern, "xx") {
+		class, err := strconv.Atoi(pattern[:1])
+		if err != nil {
+			return false, err
+		}
+		return code/100 == class, nil
+	}
+
+	if low, high, found := strings.Cut(pattern, "-"); found {
+		lowCode, err := strconv.Atoi(strings.TrimSpace(low))
+		if err != nil {
+			return false, err
+		}
+		highCode, err := strconv.Atoi(strings.TrimSpace(high))
+		if err != nil {
+			return false, err
+		}
+		return code >= lowCode && code <= highCode, nil
+	}
+
+	exact, err := strconv.Atoi(pattern)
+	if err != nil {
+		return false, err
+	}
+	return code == exact, nil
+}
+
+// lookupJSONPath resolves a dotted path such as "data.items[0].status" in a decoded JSON document
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, found := node[key]
+			if !found {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// containsString reports whether values contains want
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,470 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"networkmonitor/shared"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// bannerDialog describes how a mail or directory protocol greets a client and upgrades to TLS
+type bannerDialog struct {
+	port       string
+	securePort string
+	greet      func(r *bufio.Reader, w io.Writer) (string, error)
+	startTLS   func(r *bufio.Reader, w io.Writer) error
+	quit       func(w io.Writer)
+}
+
+// bannerDialogs maps probe types to their protocol dialogs
+var bannerDialogs = map[string]bannerDialog{
+	shared.ProbeSMTP: {port: "25", securePort: "465", greet: greetSMTP, startTLS: startTLSSMTP, quit: quitSMTP},
+	shared.ProbeIMAP: {port: "143", securePort: "993", greet: greetIMAP, startTLS: startTLSIMAP, quit: quitIMAP},
+	shared.ProbeLDAP: {port: "389", securePort: "636", greet: greetLDAP, startTLS: startTLSLDAP, quit: quitLDAP},
+}
+
+// errStartTLSUnsupported is returned when a server does not offer STARTTLS
+var errStartTLSUnsupported = errors.New("server does not offer STARTTLS")
+
+// makeBannerProbe connects to a mail or directory server, reads its greeting and optionally upgrades to TLS
+func (m *Monitor) makeBannerProbe(target shared.Target) {
+	dialog := bannerDialogs[target.Type]
+
+	address := target.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		port := dialog.port
+		if target.Secure {
+			port = dialog.securePort
+		}
+		address = net.JoinHostPort(address, port)
+	}
+
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = target.Type + "://" + address
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = target.Type
+	result.Banner = &shared.BannerResult{}
+
+	fail := func(stage string, err error) {
+		result.Banner.FailedStage = stage
+		result.Error = fmt.Sprintf("%s: %v", stage, err)
+		result.ErrorType = classifyBannerError(stage, err)
+		m.finishResult(&result)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		fail("connect", err)
+		return
+	}
+
+	tlsConfig, err := targetTLSConfig(target)
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = "config"
+		m.finishResult(&result)
+		return
+	}
+
+	family := targetFamily(target)
+	dnsStart := time.Now()
+	ip, resolved, err := resolveIP(ctx, host, family)
+	result.DNSTime = time.Since(dnsStart).Milliseconds()
+	if err != nil {
+		fail("connect", err)
+		return
+	}
+
+	connectStart := time.Now()
+	var conn net.Conn
+	conn, err = m.dialContext(ctx, familyNetwork("tcp", family), net.JoinHostPort(ip.String(), port))
+	result.TCPTime = time.Since(connectStart).Milliseconds()
+	if err != nil {
+		fail("connect", err)
+		return
+	}
+	defer func() { conn.Close() }()
+
+	result.RemoteAddr = conn.RemoteAddr().String()
+	result.Family = newFamilyInfo(family, resolved, result.RemoteAddr)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// handshake upgrades the connection to TLS, verifying the certificate against the host name.
+	// Verification is done by hand so a rejected certificate is still captured.
+	handshake := func() error {
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			result.TLS = newTLSInfo(state)
+			if tlsConfig.InsecureSkipVerify {
+				return nil
+			}
+			return verifyPeer(state, config.ServerName, tlsConfig.RootCAs)
+		}
+
+		tlsStart := time.Now()
+		tlsConn := tls.Client(conn, config)
+		err := tlsConn.HandshakeContext(ctx)
+		result.TLSTime = time.Since(tlsStart).Milliseconds()
+		if err != nil {
+			return err
+		}
+		result.TLS = newTLSInfo(tlsConn.ConnectionState())
+		conn = tlsConn
+		return nil
+	}
+
+	if target.Secure {
+		if err := handshake(); err != nil {
+			fail("tls", err)
+			return
+		}
+	}
+
+	bannerStart := time.Now()
+	reader := bufio.NewReader(conn)
+	banner, err := dialog.greet(reader, conn)
+	result.Banner.BannerTime = time.Since(bannerStart).Milliseconds()
+	if err != nil {
+		fail("banner", err)
+		return
+	}
+	result.Banner.Banner = banner
+
+	if target.StartTLS && !target.Secure {
+		requestStart := time.Now()
+		err := dialog.startTLS(reader, conn)
+		result.RequestTime = time.Since(requestStart).Milliseconds()
+		if err != nil {
+			fail("starttls", err)
+			return
+		}
+		// Anything buffered before the handshake would have been sent in the clear
+		if reader.Buffered() > 0 {
+			fail("starttls", errors.New("server sent data before the TLS handshake"))
+			return
+		}
+		if err := handshake(); err != nil {
+			fail("tls", err)
+			return
+		}
+		result.Banner.StartTLS = true
+	}
+
+	result.TCPInfo = connTCPInfo(conn)
+	dialog.quit(conn)
+	m.finishResult(&result)
+}
+
+// verifyPeer verifies the certificate chain a server presented for host against roots, or the system roots when nil
+func verifyPeer(state tls.ConnectionState, host string, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	options := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(options)
+	return err
+}
+
+// classifyBannerError maps an error at a stage of a banner probe to an error type
+func classifyBannerError(stage string, err error) string {
+	errorType := classifyDialError(err)
+	if stage == "connect" || errorType == "timeout" {
+		return errorType
+	}
+	if tlsType := classifyTLSError(err); stage == "tls" && tlsType != "" {
+		return tlsType
+	}
+	return stage
+}
+
+// greetSMTP reads the 220 greeting of an SMTP server
+func greetSMTP(r *bufio.Reader, w io.Writer) (string, error) {
+	_, message, err := textproto.NewReader(r).ReadResponse(220)
+	if err != nil {
+		return "", err
+	}
+	return firstLine(message), nil
+}
+
+// startTLSSMTP checks that STARTTLS is advertised in the EHLO reply and issues it
+func startTLSSMTP(r *bufio.Reader, w io.Writer) error {
+	tp := textproto.NewReader(r)
+
+	if _, err := io.WriteString(w, "EHLO networkmonitor\r\n"); err != nil {
+		return err
+	}
+	_, extensions, err := tp.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+	if !containsLine(extensions, "STARTTLS") {
+		return errStartTLSUnsupported
+	}
+
+	if _, err := io.WriteString(w, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	_, _, err = tp.ReadResponse(220)
+	return err
+}
+
+// quitSMTP ends an SMTP session
+func quitSMTP(w io.Writer) {
+	io.WriteString(w, "QUIT\r\n")
+}
+
+// greetIMAP reads the untagged greeting of an IMAP server
+func greetIMAP(r *bufio.Reader, w io.Writer) (string, error) {
+	line, err := textproto.NewReader(r).ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+		return "", fmt.Errorf("unexpected greeting %q", line)
+	}
+	return line, nil
+}
+
+// startTLSIMAP issues STARTTLS and waits for its tagged reply
+func startTLSIMAP(r *bufio.Reader, w io.Writer) error {
+	tp := textproto.NewReader(r)
+
+	if _, err := io.WriteString(w, "a0 CAPABILITY\r\n"); err != nil {
+		return err
+	}
+	untagged, status, err := readIMAPTagged(tp, "a0")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(status, "a0 OK") {
+		return fmt.Errorf("unexpected reply %q", status)
+	}
+	if !imapCapable(untagged, "STARTTLS") {
+		return errStartTLSUnsupported
+	}
+
+	if _, err := io.WriteString(w, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	_, status, err = readIMAPTagged(tp, "a1")
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(status, "a1 OK") {
+		return nil
+	}
+	if strings.HasPrefix(status, "a1 BAD") || strings.HasPrefix(status, "a1 NO") {
+		return fmt.Errorf("%w: %s", errStartTLSUnsupported, status)
+	}
+	return fmt.Errorf("unexpected reply %q", status)
+}
+
+// imapCapable reports whether an untagged CAPABILITY response lists capability
+func imapCapable(untagged []string, capability string) bool {
+	for _, line := range untagged {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "*" || !strings.EqualFold(fields[1], "CAPABILITY") {
+			continue
+		}
+		for _, field := range fields[2:] {
+			if strings.EqualFold(field, capability) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readIMAPTagged reads until the response tagged with tag, returning the untagged lines sent before it
+func readIMAPTagged(tp *textproto.Reader, tag string) ([]string, string, error) {
+	var untagged []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return untagged, "", err
+		}
+		if strings.HasPrefix(line, tag+" ") {
+			return untagged, line, nil
+		}
+		untagged = append(untagged, line)
+	}
+}
+
+// quitIMAP ends an IMAP session
+func quitIMAP(w io.Writer) {
+	io.WriteString(w, "a2 LOGOUT\r\n")
+}
+
+// LDAP messages are sent pre-encoded, each with its own message ID
+var (
+	// ldapAnonymousBind is a version 3 simple bind with an empty name and password
+	ldapAnonymousBind = []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x60, 0x07, 0x02, 0x01, 0x03, 0x04, 0x00, 0x80, 0x00}
+
+	// ldapStartTLS is an extended request for the StartTLS operation, OID 1.3.6.1.4.1.1466.20037
+	ldapStartTLS = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x02, 0x77, 0x18, 0x80, 0x16}, "1.3.6.1.4.1.1466.20037"...)
+
+	ldapUnbind = []byte{0x30, 0x05, 0x02, 0x01, 0x03, 0x42, 0x00}
+)
+
+// LDAP protocol operation tags of the responses read by the probe
+const (
+	ldapBindResponse     = 1
+	ldapExtendedResponse = 24
+)
+
+// maxBERElementSize bounds the LDAP responses read, which are small, so a server announcing
+// a huge length cannot make the probe allocate it
+const maxBERElementSize = 64 * 1024
+
+// greetLDAP performs an anonymous bind, as LDAP servers send no banner of their own.
+// Servers that refuse anonymous binds still answer, so any bind result counts as a greeting.
+func greetLDAP(r *bufio.Reader, w io.Writer) (string, error) {
+	if _, err := w.Write(ldapAnonymousBind); err != nil {
+		return "", err
+	}
+	code, message, err := readLDAPResult(r, ldapBindResponse)
+	if err != nil {
+		return "", err
+	}
+	banner := fmt.Sprintf("bind result code %d", code)
+	if message != "" {
+		banner += ": " + message
+	}
+	return banner, nil
+}
+
+// startTLSLDAP sends the StartTLS extended operation
+func startTLSLDAP(r *bufio.Reader, w io.Writer) error {
+	if _, err := w.Write(ldapStartTLS); err != nil {
+		return err
+	}
+	code, message, err := readLDAPResult(r, ldapExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("%w: result code %d: %s", errStartTLSUnsupported, code, message)
+	}
+	return nil
+}
+
+// quitLDAP ends an LDAP session
+func quitLDAP(w io.Writer) {
+	w.Write(ldapUnbind)
+}
+
+// ldapResult is the part of an LDAP response shared by all operations
+type ldapResult struct {
+	ResultCode        asn1.Enumerated
+	MatchedDN         []byte
+	DiagnosticMessage []byte
+}
+
+// readLDAPResult reads one LDAP message and returns the result code and diagnostic message of the expected operation
+func readLDAPResult(r *bufio.Reader, operation int) (int, string, error) {
+	element, err := readBERElement(r)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var message struct {
+		MessageID int
+		Operation asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(element, &message); err != nil {
+		return 0, "", fmt.Errorf("malformed LDAP message: %w", err)
+	}
+	if message.Operation.Class != asn1.ClassApplication || message.Operation.Tag != operation {
+		return 0, "", fmt.Errorf("unexpected LDAP operation %d", message.Operation.Tag)
+	}
+
+	// The operation is tagged as an application type but encoded like a plain sequence
+	var result ldapResult
+	if _, err := asn1.UnmarshalWithParams(message.Operation.FullBytes, &result, fmt.Sprintf("application,tag:%d", operation)); err != nil {
+		return 0, "", fmt.Errorf("malformed LDAP result: %w", err)
+	}
+	return int(result.ResultCode), string(result.DiagnosticMessage), nil
+}
+
+// readBERElement reads a single BER encoded element with a definite length
+func readBERElement(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		count := length & 0x7f
+		if count == 0 || count > 4 {
+			return nil, errors.New("unsupported BER length")
+		}
+		lengthBytes := make([]byte, count)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+
+	if length > maxBERElementSize {
+		return nil, fmt.Errorf("BER element of %d bytes exceeds %d", length, maxBERElementSize)
+	}
+
+	element := make([]byte, len(header)+length)
+	copy(element, header)
+	if _, err := io.ReadFull(r, element[len(header):]); err != nil {
+		return nil, err
+	}
+	return element, nil
+}
+
+// firstLine returns the first line of a multi-line reply
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+// containsLine reports whether a multi-line reply has a line starting with keyword
+func containsLine(text, keyword string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.EqualFold(fields[0], keyword) {
+			return true
+		}
+	}
+	return false
+}
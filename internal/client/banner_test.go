@@ -0,0 +1,460 @@
+package client
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/textproto"
+	"networkmonitor/shared"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubDelay is how long the stub servers wait before the replies whose timing is checked
+const stubDelay = 50 * time.Millisecond
+
+// startStub runs handle for each connection accepted on a local listener and returns its address
+func startStub(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// stubCertificate creates a self-signed certificate for 127.0.0.1 and writes it to a CA file for the probe
+func stubCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stub"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("write ca file: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+// runBannerProbe probes target and returns its result
+func runBannerProbe(t *testing.T, target shared.Target) shared.NetworkRequest {
+	t.Helper()
+
+	if target.Timeout == 0 {
+		target.Timeout = 5000
+	}
+	m := NewMonitor()
+	m.makeBannerProbe(target)
+
+	select {
+	case result := <-m.resultChan:
+		return result
+	case <-time.After(10 * time.Second):
+		t.Fatal("probe produced no result")
+		return shared.NetworkRequest{}
+	}
+}
+
+// serveSMTP answers the greeting and EHLO, advertising STARTTLS when cert is set, and upgrades on request
+func serveSMTP(cert *tls.Certificate) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+
+		time.Sleep(stubDelay)
+		tp.PrintfLine("220 stub.example ESMTP ready")
+
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				if cert != nil {
+					tp.PrintfLine("250-stub.example")
+					tp.PrintfLine("250 STARTTLS")
+				} else {
+					tp.PrintfLine("250 stub.example")
+				}
+			case line == "STARTTLS":
+				time.Sleep(stubDelay)
+				tp.PrintfLine("220 go ahead")
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*cert}})
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				tp = textproto.NewConn(tlsConn)
+			case line == "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			}
+		}
+	}
+}
+
+func TestSMTPGreetingAndStartTLS(t *testing.T) {
+	cert, caFile := stubCertificate(t)
+	address := startStub(t, serveSMTP(&cert))
+
+	result := runBannerProbe(t, shared.Target{
+		Name:     "smtp",
+		Type:     shared.ProbeSMTP,
+		Address:  address,
+		StartTLS: true,
+		TLS:      &shared.TLSConfig{CAFile: caFile},
+	})
+
+	if result.Error != "" {
+		t.Fatalf("unexpected error %q (%s)", result.Error, result.ErrorType)
+	}
+	if result.Banner.Banner != "stub.example ESMTP ready" {
+		t.Errorf("banner = %q", result.Banner.Banner)
+	}
+	if !result.Banner.StartTLS || result.TLS == nil {
+		t.Errorf("connection was not upgraded: startTls=%v tls=%v", result.Banner.StartTLS, result.TLS)
+	}
+	if result.Banner.BannerTime < stubDelay.Milliseconds() {
+		t.Errorf("banner time %dms, want at least %dms", result.Banner.BannerTime, stubDelay.Milliseconds())
+	}
+	if result.RequestTime < stubDelay.Milliseconds() {
+		t.Errorf("starttls time %dms, want at least %dms", result.RequestTime, stubDelay.Milliseconds())
+	}
+	if result.TotalTime < result.Banner.BannerTime+result.RequestTime {
+		t.Errorf("total time %dms is less than its parts", result.TotalTime)
+	}
+}
+
+func TestSMTPWithoutStartTLS(t *testing.T) {
+	address := startStub(t, serveSMTP(nil))
+
+	result := runBannerProbe(t, shared.Target{Name: "smtp", Type: shared.ProbeSMTP, Address: address, StartTLS: true})
+
+	if result.Banner.FailedStage != "starttls" || result.ErrorType != "starttls" {
+		t.Errorf("failed stage %q, error type %q, want starttls", result.Banner.FailedStage, result.ErrorType)
+	}
+	if !strings.Contains(result.Error, errStartTLSUnsupported.Error()) {
+		t.Errorf("error = %q", result.Error)
+	}
+}
+
+func TestSMTPRejectedGreeting(t *testing.T) {
+	address := startStub(t, func(conn net.Conn) {
+		textproto.NewConn(conn).PrintfLine("554 no service")
+	})
+
+	result := runBannerProbe(t, shared.Target{Name: "smtp", Type: shared.ProbeSMTP, Address: address})
+
+	if result.ErrorType != "banner" || !strings.Contains(result.Error, "554") {
+		t.Errorf("error %q (%s), want the 554 reply classified as banner", result.Error, result.ErrorType)
+	}
+}
+
+func TestSMTPUntrustedCertificate(t *testing.T) {
+	cert, _ := stubCertificate(t)
+	address := startStub(t, serveSMTP(&cert))
+
+	// Without the stub's CA the system roots reject the certificate
+	result := runBannerProbe(t, shared.Target{Name: "smtp", Type: shared.ProbeSMTP, Address: address, StartTLS: true})
+
+	if result.Banner.FailedStage != "tls" || result.ErrorType != "tls_unknown_authority" {
+		t.Errorf("failed stage %q, error type %q, want tls_unknown_authority", result.Banner.FailedStage, result.ErrorType)
+	}
+	if result.TLS == nil {
+		t.Error("rejected certificate was not captured")
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	result := runBannerProbe(t, shared.Target{Name: "smtp", Type: shared.ProbeSMTP, Address: address})
+
+	if result.Banner.FailedStage != "connect" || result.ErrorType != "refused" {
+		t.Errorf("failed stage %q, error type %q, want refused", result.Banner.FailedStage, result.ErrorType)
+	}
+}
+
+// serveIMAP greets and answers CAPABILITY, advertising STARTTLS when cert is set, and upgrades on request
+func serveIMAP(cert *tls.Certificate) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+
+		time.Sleep(stubDelay)
+		tp.PrintfLine("* OK [CAPABILITY IMAP4rev1 STARTTLS] stub ready")
+
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			tag, command, _ := strings.Cut(line, " ")
+			switch command {
+			case "CAPABILITY":
+				if cert != nil {
+					tp.PrintfLine("* CAPABILITY IMAP4rev1 STARTTLS")
+				} else {
+					tp.PrintfLine("* CAPABILITY IMAP4rev1")
+				}
+				tp.PrintfLine("%s OK CAPABILITY completed", tag)
+			case "STARTTLS":
+				if cert == nil {
+					tp.PrintfLine("%s BAD STARTTLS not supported", tag)
+					continue
+				}
+				time.Sleep(stubDelay)
+				// An untagged response before the tagged one must be skipped
+				tp.PrintfLine("* NOTE upgrading")
+				tp.PrintfLine("%s OK begin TLS", tag)
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*cert}})
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				tp = textproto.NewConn(tlsConn)
+			case "LOGOUT":
+				tp.PrintfLine("* BYE")
+				tp.PrintfLine("%s OK LOGOUT completed", tag)
+				return
+			}
+		}
+	}
+}
+
+func TestIMAPGreetingAndStartTLS(t *testing.T) {
+	cert, caFile := stubCertificate(t)
+	address := startStub(t, serveIMAP(&cert))
+
+	result := runBannerProbe(t, shared.Target{
+		Name:     "imap",
+		Type:     shared.ProbeIMAP,
+		Address:  address,
+		StartTLS: true,
+		TLS:      &shared.TLSConfig{CAFile: caFile},
+	})
+
+	if result.Error != "" {
+		t.Fatalf("unexpected error %q (%s)", result.Error, result.ErrorType)
+	}
+	if !strings.HasPrefix(result.Banner.Banner, "* OK") || !strings.Contains(result.Banner.Banner, "CAPABILITY") {
+		t.Errorf("banner = %q", result.Banner.Banner)
+	}
+	if !result.Banner.StartTLS {
+		t.Error("connection was not upgraded")
+	}
+	if result.Banner.BannerTime < stubDelay.Milliseconds() || result.RequestTime < stubDelay.Milliseconds() {
+		t.Errorf("banner time %dms, starttls time %dms, want at least %dms", result.Banner.BannerTime, result.RequestTime, stubDelay.Milliseconds())
+	}
+}
+
+func TestIMAPWithoutStartTLSCapability(t *testing.T) {
+	address := startStub(t, serveIMAP(nil))
+
+	result := runBannerProbe(t, shared.Target{Name: "imap", Type: shared.ProbeIMAP, Address: address, StartTLS: true})
+
+	if result.Banner.FailedStage != "starttls" || result.ErrorType != "starttls" {
+		t.Errorf("failed stage %q, error type %q, want starttls", result.Banner.FailedStage, result.ErrorType)
+	}
+	if !strings.HasSuffix(result.Error, errStartTLSUnsupported.Error()) {
+		t.Errorf("error = %q, want the missing capability reported", result.Error)
+	}
+}
+
+func TestIMAPUnexpectedGreeting(t *testing.T) {
+	address := startStub(t, func(conn net.Conn) {
+		textproto.NewConn(conn).PrintfLine("* BYE too busy")
+	})
+
+	result := runBannerProbe(t, shared.Target{Name: "imap", Type: shared.ProbeIMAP, Address: address})
+
+	if result.Banner.FailedStage != "banner" || result.ErrorType != "banner" {
+		t.Errorf("failed stage %q, error type %q, want banner", result.Banner.FailedStage, result.ErrorType)
+	}
+}
+
+func TestIMAPStartTLSRefused(t *testing.T) {
+	address := startStub(t, func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("* OK stub ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			tag, command, _ := strings.Cut(line, " ")
+			if command == "CAPABILITY" {
+				tp.PrintfLine("* CAPABILITY IMAP4rev1 STARTTLS")
+				tp.PrintfLine("%s OK CAPABILITY completed", tag)
+				continue
+			}
+			tp.PrintfLine("%s NO TLS unavailable", tag)
+		}
+	})
+
+	result := runBannerProbe(t, shared.Target{Name: "imap", Type: shared.ProbeIMAP, Address: address, StartTLS: true})
+
+	if result.ErrorType != "starttls" || !strings.Contains(result.Error, "a1 NO TLS unavailable") {
+		t.Errorf("error %q (%s), want the refused STARTTLS reported", result.Error, result.ErrorType)
+	}
+}
+
+// ldapResponse encodes an LDAP response with the given message ID, operation tag and result code
+func ldapResponse(messageID, operation, code byte) []byte {
+	return []byte{0x30, 0x0c, 0x02, 0x01, messageID, 0x60 | operation, 0x07, 0x0a, 0x01, code, 0x04, 0x00, 0x04, 0x00}
+}
+
+// serveLDAP answers the anonymous bind with bindCode, accepts StartTLS when cert is set and waits for the unbind
+func serveLDAP(bindCode byte, cert *tls.Certificate, unbound chan<- bool) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		var writer net.Conn = conn
+
+		for {
+			request, err := readBERElement(reader)
+			if err != nil {
+				return
+			}
+			messageID, operation := request[4], request[5]
+
+			switch operation {
+			case 0x60: // bind request
+				time.Sleep(stubDelay)
+				writer.Write(ldapResponse(messageID, ldapBindResponse, bindCode))
+			case 0x77: // extended request
+				if cert == nil {
+					writer.Write(ldapResponse(messageID, ldapExtendedResponse, 2)) // protocolError
+					continue
+				}
+				time.Sleep(stubDelay)
+				writer.Write(ldapResponse(messageID, ldapExtendedResponse, 0))
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*cert}})
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				reader = bufio.NewReader(tlsConn)
+				writer = tlsConn
+			case 0x42: // unbind request
+				unbound <- true
+				return
+			}
+		}
+	}
+}
+
+func TestLDAPBindStartTLSAndUnbind(t *testing.T) {
+	cert, caFile := stubCertificate(t)
+	unbound := make(chan bool, 1)
+	address := startStub(t, serveLDAP(0, &cert, unbound))
+
+	result := runBannerProbe(t, shared.Target{
+		Name:     "ldap",
+		Type:     shared.ProbeLDAP,
+		Address:  address,
+		StartTLS: true,
+		TLS:      &shared.TLSConfig{CAFile: caFile},
+	})
+
+	if result.Error != "" {
+		t.Fatalf("unexpected error %q (%s)", result.Error, result.ErrorType)
+	}
+	if result.Banner.Banner != "bind result code 0" {
+		t.Errorf("banner = %q", result.Banner.Banner)
+	}
+	if !result.Banner.StartTLS || result.TLS == nil {
+		t.Error("connection was not upgraded")
+	}
+	if result.Banner.BannerTime < stubDelay.Milliseconds() || result.RequestTime < stubDelay.Milliseconds() {
+		t.Errorf("bind time %dms, starttls time %dms, want at least %dms", result.Banner.BannerTime, result.RequestTime, stubDelay.Milliseconds())
+	}
+
+	select {
+	case <-unbound:
+	case <-time.After(2 * time.Second):
+		t.Error("probe did not unbind")
+	}
+}
+
+func TestLDAPRefusedBindCountsAsGreeting(t *testing.T) {
+	unbound := make(chan bool, 1)
+	address := startStub(t, serveLDAP(48, nil, unbound)) // inappropriateAuthentication
+
+	result := runBannerProbe(t, shared.Target{Name: "ldap", Type: shared.ProbeLDAP, Address: address})
+
+	if result.Error != "" {
+		t.Fatalf("unexpected error %q (%s)", result.Error, result.ErrorType)
+	}
+	if result.Banner.Banner != "bind result code 48" {
+		t.Errorf("banner = %q", result.Banner.Banner)
+	}
+}
+
+func TestLDAPStartTLSRejected(t *testing.T) {
+	unbound := make(chan bool, 1)
+	address := startStub(t, serveLDAP(0, nil, unbound))
+
+	result := runBannerProbe(t, shared.Target{Name: "ldap", Type: shared.ProbeLDAP, Address: address, StartTLS: true})
+
+	if result.Banner.FailedStage != "starttls" || result.ErrorType != "starttls" {
+		t.Errorf("failed stage %q, error type %q, want starttls", result.Banner.FailedStage, result.ErrorType)
+	}
+	if !strings.Contains(result.Error, "result code 2") {
+		t.Errorf("error = %q", result.Error)
+	}
+}
+
+func TestReadBERElementRejectsHugeLength(t *testing.T) {
+	// A sequence announcing 4 GiB - 1 bytes with four length bytes
+	reader := bufio.NewReader(strings.NewReader("\x30\x84\xff\xff\xff\xff"))
+
+	if _, err := readBERElement(reader); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("err = %v, want the length rejected", err)
+	}
+}
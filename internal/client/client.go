@@ -3,7 +3,9 @@ package client
 import (
 	"fmt"
 	"networkmonitor/shared"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Client is the main client application
@@ -12,8 +14,14 @@ type Client struct {
 	monitor    *Monitor
 	connection *Connection
 	systray    *SystrayHandler
+	spool      *Spool
 	stopChan   chan struct{}
 	wg         sync.WaitGroup
+
+	networkState string // latest captive portal check result
+	stateMutex   sync.Mutex
+
+	configMutex sync.RWMutex // guards config, which UpdateConfig replaces while the probe loops read it
 }
 
 // NewClient creates a new client instance
@@ -28,11 +36,23 @@ func NewClient() (*Client, error) {
 	monitor := NewMonitor()
 	connection := NewConnection(config.ServerAddress, config.ClientName)
 
+	// Results that cannot be sent wait in the data directory
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate data directory: %w", err)
+	}
+	spool, err := NewSpool(filepath.Join(dataDir, "spool"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	spool.SetLimits(config.SpoolMaxSize, time.Duration(config.SpoolMaxAge)*time.Hour)
+
 	// Create client
 	client := &Client{
 		config:     config,
 		monitor:    monitor,
 		connection: connection,
+		spool:      spool,
 		stopChan:   make(chan struct{}),
 	}
 
@@ -47,7 +67,8 @@ func (c *Client) Start() error {
 	fmt.Println("Starting Network Monitor Client...")
 
 	// Start monitor
-	c.monitor.Start(c.config.Targets)
+	config := c.currentConfig()
+	c.monitor.Start(applyDefaultProxy(config.Targets, config.Proxy))
 
 	// Start connection management
 	c.wg.Add(1)
@@ -63,6 +84,34 @@ func (c *Client) Start() error {
 		c.processNetworkRequests()
 	}()
 
+	// Start reporting host resource usage
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.reportHostMetrics()
+	}()
+
+	// Start probing the default gateway and DNS resolvers
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.probeFirstHop()
+	}()
+
+	// Start checking for captive portals and interception
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.checkPortal()
+	}()
+
+	// Start replaying spooled results whenever the server is reachable
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.forwardSpool()
+	}()
+
 	// Start systray
 	c.systray.Start()
 
@@ -89,6 +138,10 @@ func (c *Client) Stop() {
 	// Wait for goroutines to finish
 	c.wg.Wait()
 
+	// Results still spooled are replayed on the next start, along with those never acknowledged
+	c.spoolUnacked(c.connection)
+	c.spool.Close()
+
 	fmt.Println("Network Monitor Client stopped")
 }
 
@@ -100,14 +153,18 @@ func (c *Client) UpdateConfig(config shared.ClientConfig) error {
 	}
 
 	// Update client configuration
+	c.configMutex.Lock()
 	c.config = config
+	c.configMutex.Unlock()
+	c.spool.SetLimits(config.SpoolMaxSize, time.Duration(config.SpoolMaxAge)*time.Hour)
 
 	// Restart monitor with new targets
-	c.monitor.Start(config.Targets)
+	c.monitor.Start(applyDefaultProxy(config.Targets, config.Proxy))
 
 	// Update connection if server address changed
 	if c.connection.serverURL != config.ServerAddress {
 		c.connection.Disconnect()
+		c.spoolUnacked(c.connection)
 		c.connection = NewConnection(config.ServerAddress, config.ClientName)
 		c.connection.Connect()
 	}
@@ -115,6 +172,13 @@ func (c *Client) UpdateConfig(config shared.ClientConfig) error {
 	return nil
 }
 
+// currentConfig returns the client configuration
+func (c *Client) currentConfig() shared.ClientConfig {
+	c.configMutex.RLock()
+	defer c.configMutex.RUnlock()
+	return c.config
+}
+
 // processNetworkRequests processes network requests from the monitor
 func (c *Client) processNetworkRequests() {
 	resultChan := c.monitor.GetResultChan()
@@ -124,9 +188,21 @@ func (c *Client) processNetworkRequests() {
 		case <-c.stopChan:
 			return
 		case result := <-resultChan:
-			// Send network request to server if connected
-			if c.connection.IsConnected() {
-				c.connection.SendMessage(shared.TypeNetworkRequest, result)
+			// Tag results taken while the network was not open, so the server can discount them
+			if state := c.currentNetworkState(); state != shared.NetworkStateOpen {
+				result.NetworkState = state
+			}
+
+			// Send network request to server if connected, spooling it otherwise.
+			// While spooled results are waiting new ones join them so the server receives them in order.
+			sent := false
+			if c.spool.Pending() == 0 && c.connection.ReadyForResults() {
+				sent = c.connection.SendMessage(shared.TypeNetworkRequest, result)
+			}
+			if !sent {
+				if err := c.spool.Add(shared.TypeNetworkRequest, result); err != nil {
+					fmt.Printf("Error spooling result: %v\n", err)
+				}
 			}
 			
 			// Log result
@@ -134,10 +210,40 @@ func (c *Client) processNetworkRequests() {
 			if result.Error != "" {
 				fmt.Printf("[%s] Error: %s - %s (%s)\n", 
 					result.TargetName, result.URL, result.Error, result.ErrorType)
+			} else if result.Assertion != nil && !result.Assertion.Passed {
+				fmt.Printf("[%s] %d: %s assertion failed: %s (%dms)\n", 
+					result.TargetName, status, result.URL, result.Assertion.Failure, result.TotalTime)
 			} else {
 				fmt.Printf("[%s] %d: %s (%dms)\n", 
 					result.TargetName, status, result.URL, result.TotalTime)
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// reportHostMetrics periodically sends the machine's resource usage to the server
+func (c *Client) reportHostMetrics() {
+	// Take a first sample so the CPU usage of the next one covers a full interval
+	collectHostMetrics()
+
+	for {
+		// The interval is read each time so configuration updates apply to the next sample
+		select {
+		case <-c.stopChan:
+			return
+		case <-time.After(metricsInterval(c.currentConfig())):
+			if c.connection.IsConnected() {
+				c.connection.SendMessage(shared.TypeHostMetrics, collectHostMetrics())
+			}
+		}
+	}
+}
+
+// spoolUnacked moves the results a connection is still waiting to have acknowledged to the spool
+func (c *Client) spoolUnacked(connection *Connection) {
+	for _, msg := range connection.TakeUnacked() {
+		if err := c.spool.Add(msg.Type, msg.Data); err != nil {
+			fmt.Printf("Error spooling result: %v\n", err)
+		}
+	}
+}
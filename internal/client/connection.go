@@ -25,8 +25,22 @@ type Connection struct {
 	wg            sync.WaitGroup
 	connected     bool
 	mutex         sync.Mutex
+	sequence      uint64                 // of the last message sent
+	unacked       []shared.ClientMessage // results sent but not yet acknowledged, oldest first
+	ackMutex      sync.Mutex
+	retransmitted chan struct{} // closed once the results unacknowledged at connect are queued again
 }
 
+const (
+	// maxUnacked bounds the results kept for retransmission, so a server that never acknowledges
+	// them does not grow the client without limit. Further results are refused until acks arrive
+	// and wait in the spool instead.
+	maxUnacked = 1000
+
+	// retransmitTimeout is how long retransmission waits for room in the send channel
+	retransmitTimeout = 10 * time.Second
+)
+
 // NewConnection creates a new server connection
 func NewConnection(serverAddress, clientName string) *Connection {
 	hostname, _ := os.Hostname()
@@ -95,15 +109,27 @@ func (c *Connection) Connect() error {
 	// Send connect message
 	c.SendMessage(shared.TypeClientConnect, c.clientInfo)
 
+	// Results that were in flight when the previous connection died are sent again,
+	// before any spooled or new ones so the server receives them in order
+	c.retransmitted = make(chan struct{})
+	if unacked := c.pendingAcks(); len(unacked) > 0 {
+		go c.retransmit(unacked, c.sendChan, c.stopChan, c.retransmitted)
+	} else {
+		close(c.retransmitted)
+	}
+
+	// Send the network environment now rather than waiting for the first heartbeat
+	go c.sendNetworkEnvironment()
+
 	return nil
 }
 
 // Disconnect closes the connection to the server
 func (c *Connection) Disconnect() {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
 
 	if !c.connected {
+		c.mutex.Unlock()
 		return
 	}
 
@@ -120,12 +146,15 @@ func (c *Connection) Disconnect() {
 	c.ws.Close()
 	c.connected = false
 
-	// Wait for goroutines to finish
+	// Wait for goroutines to finish without holding the lock, which they take before giving up
+	c.mutex.Unlock()
 	c.wg.Wait()
 
 	// Reset channels
+	c.mutex.Lock()
 	c.stopChan = make(chan struct{})
 	c.sendChan = make(chan shared.ClientMessage, 100)
+	c.mutex.Unlock()
 }
 
 // IsConnected returns whether the client is connected
@@ -135,24 +164,142 @@ func (c *Connection) IsConnected() bool {
 	return c.connected
 }
 
-// SendMessage sends a message to the server
-func (c *Connection) SendMessage(msgType string, data interface{}) {
-	msg := shared.ClientMessage{
-		Type:      msgType,
-		ClientID:  c.clientInfo.ID,
-		Timestamp: time.Now(),
-		Data:      data,
+// ReadyForResults returns whether the client is connected and has finished retransmitting
+// the results the previous connection left unacknowledged
+func (c *Connection) ReadyForResults() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.connected {
+		return false
+	}
+	select {
+	case <-c.retransmitted:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendMessage sends a message to the server, reporting whether it could be queued
+func (c *Connection) SendMessage(msgType string, data interface{}) bool {
+	msg, ok := c.newMessage(msgType, data)
+	if !ok {
+		return false
 	}
 
 	select {
 	case c.sendChan <- msg:
 		// Message queued successfully
+		return true
 	default:
 		// Channel full, log error
 		fmt.Printf("Warning: send channel full, dropping message of type %s\n", msgType)
+		c.acknowledge(msg.Sequence)
+		return false
 	}
 }
 
+// QueueMessage sends a message to the server, waiting up to timeout for room in the send channel
+func (c *Connection) QueueMessage(msgType string, data interface{}, timeout time.Duration) bool {
+	msg, ok := c.newMessage(msgType, data)
+	if !ok {
+		return false
+	}
+
+	select {
+	case c.sendChan <- msg:
+		return true
+	case <-time.After(timeout):
+		c.acknowledge(msg.Sequence)
+		return false
+	}
+}
+
+// newMessage numbers a message and keeps results until the server acknowledges them.
+// It refuses results while maxUnacked are already waiting for acknowledgement.
+func (c *Connection) newMessage(msgType string, data interface{}) (shared.ClientMessage, bool) {
+	c.ackMutex.Lock()
+	defer c.ackMutex.Unlock()
+
+	if msgType == shared.TypeNetworkRequest && len(c.unacked) >= maxUnacked {
+		fmt.Printf("Warning: %d results awaiting acknowledgement, deferring new ones\n", len(c.unacked))
+		return shared.ClientMessage{}, false
+	}
+
+	c.sequence++
+	msg := shared.ClientMessage{
+		Type:      msgType,
+		ClientID:  c.clientInfo.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+		Sequence:  c.sequence,
+	}
+
+	if msgType == shared.TypeNetworkRequest {
+		c.unacked = append(c.unacked, msg)
+	}
+	return msg, true
+}
+
+// acknowledge stops tracking a message, once the server persisted it or it was never queued
+func (c *Connection) acknowledge(sequence uint64) {
+	c.ackMutex.Lock()
+	defer c.ackMutex.Unlock()
+
+	for i, msg := range c.unacked {
+		if msg.Sequence == sequence {
+			c.unacked = append(c.unacked[:i], c.unacked[i+1:]...)
+			return
+		}
+	}
+}
+
+// pendingAcks returns a copy of the results waiting for acknowledgement
+func (c *Connection) pendingAcks() []shared.ClientMessage {
+	c.ackMutex.Lock()
+	defer c.ackMutex.Unlock()
+
+	return append([]shared.ClientMessage(nil), c.unacked...)
+}
+
+// TakeUnacked returns the results waiting for acknowledgement and stops tracking them,
+// so they can be kept elsewhere when the connection is replaced or the client stops
+func (c *Connection) TakeUnacked() []shared.ClientMessage {
+	c.ackMutex.Lock()
+	defer c.ackMutex.Unlock()
+
+	unacked := c.unacked
+	c.unacked = nil
+	return unacked
+}
+
+// retransmit queues results again on a new connection and closes done when it is finished.
+// They keep their sequence numbers, and the server drops any it already stored.
+func (c *Connection) retransmit(messages []shared.ClientMessage, sendChan chan shared.ClientMessage, stopChan, done chan struct{}) {
+	defer close(done)
+
+	for _, msg := range messages {
+		msg.Timestamp = time.Now()
+		select {
+		case sendChan <- msg:
+		case <-stopChan:
+			return
+		case <-time.After(retransmitTimeout):
+			// Still unacknowledged, so the next connection tries again
+			return
+		}
+	}
+	fmt.Printf("Retransmitted %d unacknowledged results\n", len(messages))
+}
+
+// SetDroppedResults updates the dropped result count reported with each heartbeat
+func (c *Connection) SetDroppedResults(dropped int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clientInfo.DroppedResults = dropped
+}
+
 // sendLoop sends messages to the server
 func (c *Connection) sendLoop() {
 	defer c.wg.Done()
@@ -220,10 +367,19 @@ func (c *Connection) sendLoop() {
 				c.triggerReconnect()
 				return
 			}
+
+			// Queue a network environment snapshot with every heartbeat, collected without holding up the sends
+			go c.sendNetworkEnvironment()
 		}
 	}
 }
 
+// sendNetworkEnvironment collects a network environment snapshot and queues it. Collecting can take
+// seconds, so callers run it in its own goroutine.
+func (c *Connection) sendNetworkEnvironment() {
+	c.SendMessage(shared.TypeNetworkEnvironment, collectNetworkEnvironment())
+}
+
 // receiveLoop receives messages from the server
 func (c *Connection) receiveLoop() {
 	defer c.wg.Done()
@@ -247,6 +403,12 @@ func (c *Connection) receiveLoop() {
 			// Read message
 			_, message, err := c.ws.ReadMessage()
 			if err != nil {
+				// Closing the connection on purpose is not a reason to reconnect
+				select {
+				case <-c.stopChan:
+					return
+				default:
+				}
 				fmt.Printf("Error reading message: %v\n", err)
 				c.triggerReconnect()
 				return
@@ -268,6 +430,15 @@ func (c *Connection) receiveLoop() {
 // handleServerMessage processes messages from the server
 func (c *Connection) handleServerMessage(msg shared.ServerMessage) {
 	switch msg.Type {
+	case shared.TypeAck:
+		// The server stored a result, so it need not be sent again
+		if ackData, ok := msg.Data.(map[string]interface{}); ok {
+			var ack shared.MessageAck
+			ackBytes, _ := json.Marshal(ackData)
+			json.Unmarshal(ackBytes, &ack)
+			c.acknowledge(ack.Sequence)
+		}
+
 	case shared.TypeConfigRequest:
 		// Handle config request
 		fmt.Println("Config request received from server")
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"networkmonitor/shared"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsRecordTypes maps supported record type names to their wire types
+var dnsRecordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"SRV":   dnsmessage.TypeSRV,
+}
+
+// makeDNSProbe queries a resolver for a record and validates the answers
+func (m *Monitor) makeDNSProbe(target shared.Target) {
+	recordType := strings.ToUpper(target.RecordType)
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = dnsURL(target.Resolver, target.Address, recordType)
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeDNS
+	result.Scope = target.Scope
+	result.DNS = &shared.DNSResult{
+		Name:       target.Address,
+		RecordType: recordType,
+		Expected:   target.ExpectedAnswers,
+	}
+
+	qtype, ok := dnsRecordTypes[recordType]
+	if !ok {
+		result.Error = fmt.Sprintf("unsupported record type %q", recordType)
+		result.ErrorType = "config"
+		m.finishResult(&result)
+		return
+	}
+
+	resolver, err := resolverAddress(target.Resolver)
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = "config"
+		m.finishResult(&result)
+		return
+	}
+	result.URL = dnsURL(resolver, target.Address, recordType)
+	result.DNS.Resolver = resolver
+	result.RemoteAddr = resolver
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	dnsStart := time.Now()
+	resp, err := queryDNS(ctx, resolver, target.Address, qtype)
+	result.DNSTime = time.Since(dnsStart).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		m.finishResult(&result)
+		return
+	}
+
+	result.DNS.RCode = strings.TrimPrefix(resp.RCode.String(), "RCode")
+	result.DNS.Answers = dnsAnswers(resp.Answers)
+
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		result.Error = fmt.Sprintf("resolver returned %s", result.DNS.RCode)
+		result.ErrorType = "dns"
+	} else if len(target.ExpectedAnswers) > 0 {
+		result.DNS.Matched = answersMatch(result.DNS.Answers, recordType, target.ExpectedAnswers)
+		if !result.DNS.Matched {
+			result.Error = "answers do not match expected set"
+			result.ErrorType = "dns_mismatch"
+		}
+	} else {
+		result.DNS.Matched = true
+	}
+
+	m.finishResult(&result)
+}
+
+// dnsURL describes a DNS query as a URL for display
+func dnsURL(resolver, name, recordType string) string {
+	return fmt.Sprintf("dns://%s/%s?type=%s", resolver, name, recordType)
+}
+
+// resolverAddress returns the resolver to query, defaulting to the first system nameserver
+func resolverAddress(resolver string) (string, error) {
+	if resolver == "" {
+		servers, err := systemNameservers()
+		if err != nil {
+			return "", err
+		}
+		if len(servers) == 0 {
+			return "", errors.New("no system nameservers configured")
+		}
+		resolver = servers[0]
+	}
+
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+	return resolver, nil
+}
+
+// queryDNS sends a single question to resolver, retrying over TCP when the UDP answer is truncated
+func queryDNS(ctx context.Context, resolver, name string, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeDNS(ctx, "udp", resolver, packed, query.ID)
+	if err == nil && resp.Truncated {
+		resp, err = exchangeDNS(ctx, "tcp", resolver, packed, query.ID)
+	}
+	return resp, err
+}
+
+// exchangeDNS sends a packed query over network and waits for the matching response
+func exchangeDNS(ctx context.Context, network, resolver string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, resolver)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		// DNS over TCP prefixes each message with its length
+		framed := make([]byte, 2+len(packed))
+		binary.BigEndian.PutUint16(framed, uint16(len(packed)))
+		copy(framed[2:], packed)
+		if _, err := conn.Write(framed); err != nil {
+			return nil, err
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ignore stray datagrams that do not answer our query
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil || resp.ID != id || !resp.Response {
+			continue
+		}
+		return &resp, nil
+	}
+}
+
+// dnsAnswers converts resource records to their display form
+func dnsAnswers(records []dnsmessage.Resource) []shared.DNSAnswer {
+	answers := make([]shared.DNSAnswer, 0, len(records))
+	for _, record := range records {
+		var value string
+		switch body := record.Body.(type) {
+		case *dnsmessage.AResource:
+			value = net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			value = net.IP(body.AAAA[:]).String()
+		case *dnsmessage.CNAMEResource:
+			value = trimDot(body.CNAME.String())
+		case *dnsmessage.MXResource:
+			value = fmt.Sprintf("%d %s", body.Pref, trimDot(body.MX.String()))
+		case *dnsmessage.TXTResource:
+			value = strings.Join(body.TXT, "")
+		case *dnsmessage.SRVResource:
+			value = fmt.Sprintf("%d %d %d %s", body.Priority, body.Weight, body.Port, trimDot(body.Target.String()))
+		default:
+			continue
+		}
+
+		answers = append(answers, shared.DNSAnswer{
+			Type:  strings.TrimPrefix(record.Header.Type.String(), "Type"),
+			Value: value,
+			TTL:   record.Header.TTL,
+		})
+	}
+	return answers
+}
+
+// answersMatch reports whether the answers of recordType equal the expected set
+func answersMatch(answers []shared.DNSAnswer, recordType string, expected []string) bool {
+	var got []string
+	for _, answer := range answers {
+		if answer.Type == recordType {
+			got = append(got, normalizeAnswer(answer.Value))
+		}
+	}
+
+	want := make([]string, 0, len(expected))
+	for _, value := range expected {
+		want = append(want, normalizeAnswer(value))
+	}
+
+	if len(got) != len(want) {
+		return false
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeAnswer makes answer values comparable regardless of case and trailing dots
+func normalizeAnswer(value string) string {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+	return strings.ToLower(trimDot(value))
+}
+
+// trimDot removes the trailing dot from a fully qualified name
+func trimDot(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
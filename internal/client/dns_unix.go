@@ -0,0 +1,33 @@
+//go:build !windows
+
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// resolvConfPath is the resolver configuration read when a DNS target has no resolver
+const resolvConfPath = "/etc/resolv.conf"
+
+// systemNameservers reads the nameservers from the system resolver configuration
+func systemNameservers() ([]string, error) {
+	file, err := os.Open(resolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system resolvers: %w", err)
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+
+	return servers, scanner.Err()
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"networkmonitor/shared"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// collectNetworkEnvironment takes a snapshot of the local interfaces, routing and DNS configuration.
+// Parts that cannot be read are left empty so the rest of the snapshot is still sent.
+func collectNetworkEnvironment() shared.NetworkEnvironment {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	env := shared.NetworkEnvironment{
+		CollectedAt: time.Now(),
+		Interfaces:  []shared.NetworkInterface{},
+	}
+
+	interfaces, err := psnet.InterfacesWithContext(ctx)
+	if err != nil {
+		fmt.Printf("Error listing network interfaces: %v\n", err)
+	}
+
+	counters := make(map[string]psnet.IOCountersStat)
+	if stats, err := psnet.IOCountersWithContext(ctx, true); err == nil {
+		for _, stat := range stats {
+			counters[stat.Name] = stat
+		}
+	}
+
+	for _, iface := range interfaces {
+		info := shared.NetworkInterface{
+			Name: iface.Name,
+			MAC:  iface.HardwareAddr,
+			MTU:  iface.MTU,
+		}
+		for _, flag := range iface.Flags {
+			switch flag {
+			case "up":
+				info.Up = true
+			case "loopback":
+				info.Loopback = true
+			}
+		}
+		for _, addr := range iface.Addrs {
+			info.Addresses = append(info.Addresses, addr.Addr)
+		}
+		if stat, found := counters[iface.Name]; found {
+			info.BytesSent = stat.BytesSent
+			info.BytesRecv = stat.BytesRecv
+			info.ErrorsIn = stat.Errin
+			info.ErrorsOut = stat.Errout
+			info.DropsIn = stat.Dropin
+			info.DropsOut = stat.Dropout
+		}
+		env.Interfaces = append(env.Interfaces, info)
+	}
+
+	env.DefaultGateway, env.GatewayInterface = defaultGateway()
+	// Systems whose resolvers cannot be read report no DNS servers
+	env.DNSServers, _ = systemNameservers()
+	return env
+}
@@ -0,0 +1,53 @@
+//go:build darwin
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// routeTimeout bounds the route command, which can hang while the routing socket is busy
+const routeTimeout = 5 * time.Second
+
+// defaultGateway asks the routing table for the IPv4 default route
+func defaultGateway() (string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), routeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "/sbin/route", "-n", "get", "default").Output()
+	if err != nil {
+		return "", ""
+	}
+	return parseRouteGet(output)
+}
+
+// parseRouteGet reads the gateway and interface from the output of route get, which has lines
+// such as "    gateway: 192.168.1.1" and "  interface: en0"
+func parseRouteGet(output []byte) (string, string) {
+	var gateway, iface string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "gateway":
+			gateway = strings.TrimSpace(value)
+		case "interface":
+			iface = strings.TrimSpace(value)
+		}
+	}
+
+	// Routes through a point-to-point link name no gateway address
+	if net.ParseIP(gateway) == nil {
+		return "", iface
+	}
+	return gateway, iface
+}
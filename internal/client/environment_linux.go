@@ -0,0 +1,39 @@
+//go:build linux
+
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+)
+
+// defaultGateway reads the IPv4 default route from the kernel routing table
+func defaultGateway() (string, string) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags ..., with addresses in little-endian hex
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		gateway := make(net.IP, 4)
+		binary.BigEndian.PutUint32(gateway, binary.LittleEndian.Uint32(raw))
+		return gateway.String(), fields[0]
+	}
+	return "", ""
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package client
+
+// defaultGateway reports no gateway, since the routing table is not read on this platform
+func defaultGateway() (string, string) {
+	return "", ""
+}
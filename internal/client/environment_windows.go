@@ -0,0 +1,101 @@
+//go:build windows
+
+package client
+
+import (
+	"math"
+	"os"
+	"sort"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// gaaFlags asks GetAdaptersAddresses for gateways, which it leaves out by default,
+	// and skips the anycast and multicast addresses that are not needed
+	gaaFlags = 0x0080 | 0x0002 | 0x0004 // GAA_FLAG_INCLUDE_GATEWAYS | SKIP_ANYCAST | SKIP_MULTICAST
+)
+
+// defaultGateway returns the IPv4 gateway of the connected adapter with the lowest route metric
+func defaultGateway() (string, string) {
+	adapters, err := adapterAddresses()
+	if err != nil {
+		return "", ""
+	}
+
+	var gateway, iface string
+	metric := uint32(math.MaxUint32)
+	for aa := adapters; aa != nil; aa = aa.Next {
+		if !activeAdapter(aa) || aa.Ipv4Metric >= metric {
+			continue
+		}
+		for gw := aa.FirstGatewayAddress; gw != nil; gw = gw.Next {
+			ip := gw.Address.IP().To4()
+			if ip == nil || ip.IsUnspecified() {
+				continue
+			}
+			gateway, iface, metric = ip.String(), windows.UTF16PtrToString(aa.FriendlyName), aa.Ipv4Metric
+			break
+		}
+	}
+	return gateway, iface
+}
+
+// systemNameservers returns the DNS servers of the connected adapters, those of the adapter with
+// the lowest route metric first
+func systemNameservers() ([]string, error) {
+	adapters, err := adapterAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*windows.IpAdapterAddresses
+	for aa := adapters; aa != nil; aa = aa.Next {
+		if activeAdapter(aa) {
+			active = append(active, aa)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].Ipv4Metric < active[j].Ipv4Metric })
+
+	var servers []string
+	seen := make(map[string]bool)
+	for _, aa := range active {
+		for dns := aa.FirstDnsServerAddress; dns != nil; dns = dns.Next {
+			ip := dns.Address.IP()
+			// Adapters without IPv6 resolvers list the deprecated site-local fec0:0:0:ffff::1-3
+			if ip == nil || (ip.To4() == nil && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0) {
+				continue
+			}
+			if server := ip.String(); !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers, nil
+}
+
+// activeAdapter reports whether an adapter is up and not a loopback
+func activeAdapter(aa *windows.IpAdapterAddresses) bool {
+	return aa.OperStatus == windows.IfOperStatusUp && aa.IfType != windows.IF_TYPE_SOFTWARE_LOOPBACK
+}
+
+// adapterAddresses returns the linked list of network adapters, growing the buffer until it fits
+func adapterAddresses() (*windows.IpAdapterAddresses, error) {
+	size := uint32(15000)
+	for {
+		buf := make([]byte, size)
+		aa := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, gaaFlags, 0, aa, &size)
+		if err == nil {
+			if size == 0 {
+				return nil, nil
+			}
+			return aa, nil
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW || size <= uint32(len(buf)) {
+			return nil, os.NewSyscallError("getadaptersaddresses", err)
+		}
+	}
+}
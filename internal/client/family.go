@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"networkmonitor/shared"
+	"strings"
+)
+
+// targetFamily returns the address family forced by target, or an empty string for the system choice
+func targetFamily(target shared.Target) string {
+	return strings.ToLower(target.AddressFamily)
+}
+
+// familyNetwork restricts a dial network such as "tcp" or "udp" to family
+func familyNetwork(network, family string) string {
+	switch family {
+	case shared.FamilyIPv4:
+		return strings.TrimRight(network, "46") + "4"
+	case shared.FamilyIPv6:
+		return strings.TrimRight(network, "46") + "6"
+	default:
+		return network
+	}
+}
+
+// ipFamily returns the address family of ip
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return shared.FamilyIPv4
+	}
+	return shared.FamilyIPv6
+}
+
+// addrFamily returns the address family of a host:port or bare IP address, or an empty string if it is not an IP
+func addrFamily(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	return ipFamily(ip)
+}
+
+// resolveIP resolves host to its first IP address of family, also returning every address found
+func resolveIP(ctx context.Context, host, family string) (net.IP, []net.IPAddr, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, addr := range addrs {
+		if family == "" || ipFamily(addr.IP) == family {
+			return addr.IP, addrs, nil
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, nil, &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+	return nil, addrs, &net.DNSError{Err: fmt.Sprintf("no %s addresses found", family), Name: host, IsNotFound: true}
+}
+
+// newFamilyInfo describes the families a probe resolved and the one it connected over.
+// A host that resolves to both families but was reached over IPv4 without forcing a family fell back from IPv6.
+func newFamilyInfo(requested string, resolved []net.IPAddr, remoteAddr string) *shared.FamilyInfo {
+	info := &shared.FamilyInfo{
+		Requested: requested,
+		Used:      addrFamily(remoteAddr),
+	}
+
+	for _, addr := range resolved {
+		if ipFamily(addr.IP) == shared.FamilyIPv4 {
+			info.ResolvedIPv4 = true
+		} else {
+			info.ResolvedIPv6 = true
+		}
+	}
+	// Literal addresses are not looked up, so count the one connected to
+	switch info.Used {
+	case shared.FamilyIPv4:
+		info.ResolvedIPv4 = true
+	case shared.FamilyIPv6:
+		info.ResolvedIPv6 = true
+	}
+
+	info.Fallback = requested == "" && info.Used == shared.FamilyIPv4 && info.ResolvedIPv6
+	return info
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"networkmonitor/shared"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultFirstHopInterval is how often the gateway and resolvers are probed when the configuration does not say
+	defaultFirstHopInterval = 30 * time.Second
+
+	// firstHopTimeout keeps a dead gateway or resolver from holding up the next round
+	firstHopTimeout = 5000 // in milliseconds
+
+	// firstHopPingCount is the number of echo requests sent to the gateway per round
+	firstHopPingCount = 3
+)
+
+// firstHopInterval returns how often the first hop is probed for config
+func firstHopInterval(config shared.ClientConfig) time.Duration {
+	if config.FirstHopInterval > 0 {
+		return time.Duration(config.FirstHopInterval) * time.Second
+	}
+	return defaultFirstHopInterval
+}
+
+// firstHopTargets builds the built-in targets for the current default gateway and DNS resolvers.
+// They are discovered again every round so a change of network is followed.
+func firstHopTargets() []shared.Target {
+	var targets []shared.Target
+
+	if gateway, _ := defaultGateway(); gateway != "" {
+		targets = append(targets, shared.Target{
+			Name:    "Gateway " + gateway,
+			Type:    shared.ProbePing,
+			Address: gateway,
+			Count:   firstHopPingCount,
+			Timeout: firstHopTimeout,
+			Enabled: true,
+			Scope:   shared.ScopeGateway,
+		})
+	}
+
+	servers, _ := systemNameservers()
+	for _, server := range servers {
+		// Resolvers keep the root zone cached, so the query checks the resolver rather than the internet behind it
+		targets = append(targets, shared.Target{
+			Name:       "Resolver " + server,
+			Type:       shared.ProbeDNS,
+			Address:    ".",
+			RecordType: "A",
+			Resolver:   server,
+			Timeout:    firstHopTimeout,
+			Enabled:    true,
+			Scope:      shared.ScopeResolver,
+		})
+	}
+
+	return targets
+}
+
+// probeFirstHop periodically probes the default gateway and DNS resolvers so the server can tell
+// local network and resolver failures from upstream ones
+func (c *Client) probeFirstHop() {
+	for {
+		// The configuration is read each time so updates apply to the next round
+		if !c.currentConfig().DisableFirstHop {
+			var wg sync.WaitGroup
+			for _, target := range firstHopTargets() {
+				wg.Add(1)
+				go func(target shared.Target) {
+					defer wg.Done()
+					c.monitor.probe(target)
+				}(target)
+			}
+			wg.Wait()
+		}
+
+		select {
+		case <-c.stopChan:
+			return
+		case <-time.After(firstHopInterval(c.currentConfig())):
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"networkmonitor/shared"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// makeGRPCProbe calls the standard gRPC health service of a target and records its serving status
+func (m *Monitor) makeGRPCProbe(target shared.Target) {
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = "grpc://" + target.Address + "/" + target.Service
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeGRPC
+	result.GRPC = &shared.GRPCResult{Service: target.Service}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	family := targetFamily(target)
+	stats := &grpcDialStats{}
+
+	// Dial through the monitor so DNS and connect timings are recorded like other probes
+	dialer := func(ctx context.Context, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		dnsStart := time.Now()
+		ip, resolved, err := resolveIP(ctx, host, family)
+		dnsTime := time.Since(dnsStart).Milliseconds()
+		if err != nil {
+			stats.record(func() { stats.dnsTime = dnsTime })
+			return nil, err
+		}
+
+		connectStart := time.Now()
+		conn, err := m.dialContext(ctx, familyNetwork("tcp", family), net.JoinHostPort(ip.String(), port))
+		tcpTime := time.Since(connectStart).Milliseconds()
+
+		stats.record(func() {
+			stats.dnsTime = dnsTime
+			stats.tcpTime = tcpTime
+			if err == nil {
+				stats.conn = conn
+				stats.family = newFamilyInfo(family, resolved, conn.RemoteAddr().String())
+			}
+		})
+		return conn, err
+	}
+
+	creds := insecure.NewCredentials()
+	if target.Secure {
+		tlsConfig, err := targetTLSConfig(target)
+		if err != nil {
+			result.Error = err.Error()
+			result.ErrorType = "config"
+			m.finishResult(&result)
+			return
+		}
+		creds = timedCredentials{
+			TransportCredentials: credentials.NewTLS(tlsConfig),
+			done: func(elapsed time.Duration, state tls.ConnectionState, err error) {
+				stats.record(func() {
+					stats.tlsTime = elapsed.Milliseconds()
+					stats.handshakeErr = err
+					if err == nil {
+						stats.tls = newTLSInfo(state)
+					}
+				})
+			},
+		}
+	}
+
+	connectStart := time.Now()
+	client, err := grpc.DialContext(ctx, target.Address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(dialer),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError(),
+		grpc.FailOnNonTempDialError(true),
+	)
+	result.GRPC.ConnectTime = time.Since(connectStart).Milliseconds()
+	handshakeErr := stats.apply(&result)
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyGRPCError(err)
+		// gRPC retries failed handshakes until the deadline, so report the handshake as the cause
+		if handshakeErr != nil {
+			result.ErrorType = "tls"
+			if errorType := classifyTLSError(handshakeErr); errorType != "" {
+				result.ErrorType = errorType
+			}
+		}
+		m.finishResult(&result)
+		return
+	}
+	defer client.Close()
+
+	requestStart := time.Now()
+	resp, err := grpc_health_v1.NewHealthClient(client).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: target.Service})
+	result.RequestTime = time.Since(requestStart).Milliseconds()
+
+	if conn := stats.connection(); conn != nil {
+		result.TCPInfo = connTCPInfo(conn)
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyGRPCError(err)
+	} else {
+		result.GRPC.Status = resp.Status.String()
+		if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			result.Error = "service is " + result.GRPC.Status
+			result.ErrorType = "not_serving"
+		}
+	}
+
+	m.finishResult(&result)
+}
+
+// grpcDialStats collects what gRPC's dialing goroutines observe, which may outlive a failed dial
+type grpcDialStats struct {
+	mutex        sync.Mutex
+	dnsTime      int64
+	tcpTime      int64
+	tlsTime      int64
+	conn         net.Conn
+	family       *shared.FamilyInfo
+	tls          *shared.TLSInfo
+	handshakeErr error
+}
+
+// record updates the stats while holding the lock
+func (s *grpcDialStats) record(update func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	update()
+}
+
+// apply copies the collected stats to result and returns the last handshake error
+func (s *grpcDialStats) apply(result *shared.NetworkRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result.DNSTime = s.dnsTime
+	result.TCPTime = s.tcpTime
+	result.TLSTime = s.tlsTime
+	result.TLS = s.tls
+	result.Family = s.family
+	if s.conn != nil {
+		result.RemoteAddr = s.conn.RemoteAddr().String()
+	}
+	return s.handshakeErr
+}
+
+// connection returns the last connection dialed
+func (s *grpcDialStats) connection() net.Conn {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.conn
+}
+
+// timedCredentials reports the duration and state of each TLS handshake made with the wrapped credentials
+type timedCredentials struct {
+	credentials.TransportCredentials
+	done func(time.Duration, tls.ConnectionState, error)
+}
+
+// ClientHandshake performs the wrapped handshake and reports its timing
+func (c timedCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	start := time.Now()
+	conn, authInfo, err := c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+
+	var state tls.ConnectionState
+	if info, ok := authInfo.(credentials.TLSInfo); ok {
+		state = info.State
+	}
+	c.done(time.Since(start), state, err)
+	return conn, authInfo, err
+}
+
+// Clone copies the credentials, keeping the handshake callback
+func (c timedCredentials) Clone() credentials.TransportCredentials {
+	return timedCredentials{TransportCredentials: c.TransportCredentials.Clone(), done: c.done}
+}
+
+// classifyGRPCError maps a gRPC dial or call error to an error type
+func classifyGRPCError(err error) string {
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.DeadlineExceeded:
+			return "timeout"
+		case codes.Unavailable:
+			return "unavailable"
+		case codes.Unimplemented:
+			return "unimplemented" // the server has no health service
+		case codes.NotFound:
+			return "unknown_service"
+		default:
+			return "grpc"
+		}
+	}
+	return classifyDialError(err)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"networkmonitor/shared"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// defaultMetricsInterval is how often host metrics are reported when the configuration does not say
+const defaultMetricsInterval = 30 * time.Second
+
+// metricsInterval returns how often host metrics are reported for config
+func metricsInterval(config shared.ClientConfig) time.Duration {
+	if config.MetricsInterval > 0 {
+		return time.Duration(config.MetricsInterval) * time.Second
+	}
+	return defaultMetricsInterval
+}
+
+// collectHostMetrics samples the resource usage of the machine.
+// CPU usage is averaged since the previous call, so the first sample covers the time since start-up.
+// Values that cannot be read are left at zero so the rest of the sample is still sent.
+func collectHostMetrics() shared.HostMetrics {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	metrics := shared.HostMetrics{CollectedAt: time.Now()}
+
+	if percent, err := cpu.PercentWithContext(ctx, 0, false); err == nil && len(percent) > 0 {
+		metrics.CPUPercent = percent[0]
+	} else if err != nil {
+		fmt.Printf("Error reading CPU usage: %v\n", err)
+	}
+	if count, err := cpu.CountsWithContext(ctx, true); err == nil {
+		metrics.CPUCount = count
+	}
+
+	if memory, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		metrics.MemoryTotal = memory.Total
+		metrics.MemoryUsed = memory.Used
+		metrics.MemoryPercent = memory.UsedPercent
+	} else {
+		fmt.Printf("Error reading memory usage: %v\n", err)
+	}
+	if swap, err := mem.SwapMemoryWithContext(ctx); err == nil {
+		metrics.SwapPercent = swap.UsedPercent
+	}
+
+	// Windows has no load average
+	if avg, err := load.AvgWithContext(ctx); err == nil {
+		metrics.Load1 = avg.Load1
+		metrics.Load5 = avg.Load5
+		metrics.Load15 = avg.Load15
+	}
+
+	if pids, err := process.PidsWithContext(ctx); err == nil {
+		metrics.Processes = len(pids)
+	}
+
+	return metrics
+}
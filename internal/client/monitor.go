@@ -3,11 +3,13 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptrace"
 	"net/url"
 	"networkmonitor/shared"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,29 +18,35 @@ import (
 
 // Monitor handles HTTP request monitoring
 type Monitor struct {
-	client       *http.Client
-	targets      []shared.Target
-	resultChan   chan shared.NetworkRequest
-	stopChan     chan struct{}
-	wg           sync.WaitGroup
-	monitorMutex sync.Mutex
+	client         *http.Client
+	dialer         *net.Dialer
+	transports     map[string]http.RoundTripper // by forced protocol and address family
+	transportMutex sync.Mutex
+	targets        []shared.Target
+	resultChan     chan shared.NetworkRequest
+	stopChan       chan struct{}
+	wg             sync.WaitGroup
+	monitorMutex   sync.Mutex
 }
 
 // NewMonitor creates a new HTTP request monitor
 func NewMonitor() *Monitor {
-	return &Monitor{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				DisableKeepAlives: false,
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: false,
-				},
-			},
+	m := &Monitor{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
 		},
+		transports: make(map[string]http.RoundTripper),
 		resultChan: make(chan shared.NetworkRequest, 100),
 		stopChan:   make(chan struct{}),
 	}
+
+	m.client = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: m.newHTTPTransport("", &tls.Config{}),
+	}
+
+	return m
 }
 
 // Start begins monitoring targets
@@ -83,34 +91,119 @@ func (m *Monitor) monitorTarget(target shared.Target) {
 	defer ticker.Stop()
 
 	// Initial request
-	m.makeRequest(target)
+	m.probe(target)
 
 	for {
 		select {
 		case <-ticker.C:
-			m.makeRequest(target)
+			m.probe(target)
 		case <-m.stopChan:
 			return
 		}
 	}
 }
 
-// makeRequest performs an HTTP request and records metrics
-func (m *Monitor) makeRequest(target shared.Target) {
-	req, err := http.NewRequest(http.MethodGet, target.URL, nil)
-	if err != nil {
-		m.recordError(target, err, "request_creation")
+// probe runs the check matching the target's probe type
+func (m *Monitor) probe(target shared.Target) {
+	// Dual-stack targets are probed once over each family so both results are stored. DNS probes
+	// query a configured resolver rather than connecting to the target, so they run only once
+	if targetFamily(target) == shared.FamilyDual && target.Type != shared.ProbeDNS {
+		for _, family := range []string{shared.FamilyIPv4, shared.FamilyIPv6} {
+			familyTarget := target
+			familyTarget.AddressFamily = family
+			m.probe(familyTarget)
+		}
 		return
 	}
 
+	switch target.Type {
+	case shared.ProbeTCP:
+		m.makeTCPProbe(target)
+	case shared.ProbePing:
+		m.makePingProbe(target)
+	case shared.ProbeDNS:
+		m.makeDNSProbe(target)
+	case shared.ProbeTransaction:
+		m.makeTransaction(target)
+	case shared.ProbeTrace:
+		m.makeTraceProbe(target)
+	case shared.ProbeGRPC:
+		m.makeGRPCProbe(target)
+	case shared.ProbeWebSocket:
+		m.makeWebSocketProbe(target)
+	case shared.ProbeSMTP, shared.ProbeIMAP, shared.ProbeLDAP:
+		m.makeBannerProbe(target)
+	case shared.ProbeNTP:
+		m.makeNTPProbe(target)
+	default:
+		m.makeRequest(target)
+	}
+}
+
+// makeRequest performs an HTTP request and records metrics
+func (m *Monitor) makeRequest(target shared.Target) {
+	result, _, _ := m.doRequest(target, nil, false, func(err error, errorType string) {
+		m.recordError(target, err, errorType)
+	})
+	m.resultChan <- result
+}
+
+// doRequest performs an HTTP request for target and returns its metrics with the response.
+// The body is returned when keepBody is set or an assertion needed it. Errors seen by the
+// request trace are also passed to onError, if set; composite probes leave it nil so they
+// only appear on the returned result.
+func (m *Monitor) doRequest(target shared.Target, jar http.CookieJar, keepBody bool, onError func(err error, errorType string)) (shared.NetworkRequest, *http.Response, []byte) {
+	method := requestMethod(target)
+
 	var result shared.NetworkRequest
 	result.ID = uuid.New().String()
 	result.URL = target.URL
-	result.Method = http.MethodGet
+	result.Method = method
 	result.StartTime = time.Now()
 	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeHTTP
+
+	var reqBody io.Reader
+	if target.Body != "" {
+		reqBody = strings.NewReader(target.Body)
+	}
+
+	req, err := http.NewRequest(method, target.URL, reqBody)
+	if err != nil {
+		result.EndTime = result.StartTime
+		result.Error = err.Error()
+		result.ErrorType = "request_creation"
+		return result, nil, nil
+	}
+
+	transport, err := m.transportFor(target)
+	if err != nil {
+		result.EndTime = result.StartTime
+		result.Error = err.Error()
+		result.ErrorType = "config"
+		return result, nil, nil
+	}
+	// A forced protocol is recorded even when no response arrives
+	result.Protocol = strings.ToLower(target.Protocol)
 
 	var dnsStart, connectStart, tlsStart, requestStart, responseStart time.Time
+	var conn net.Conn
+	var resolved []net.IPAddr
+	redirects := newRedirectRecorder(target.URL)
+
+	// With a proxy the connect timings are those of the proxy, and the recorder notes when it was ready
+	ctx := context.Background()
+	var proxy *proxyRecorder
+	if usesProxy(target) {
+		proxy = &proxyRecorder{}
+		ctx = context.WithValue(ctx, proxyContextKey{}, proxy)
+	}
+
+	traceError := func(err error, errorType string) {
+		if onError != nil {
+			onError(err, errorType)
+		}
+	}
 
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(info httptrace.DNSStartInfo) {
@@ -119,85 +212,186 @@ func (m *Monitor) makeRequest(target shared.Target) {
 		DNSDone: func(info httptrace.DNSDoneInfo) {
 			if !dnsStart.IsZero() {
 				result.DNSTime = time.Since(dnsStart).Milliseconds()
+				redirects.current.DNSTime = result.DNSTime
 			}
+			resolved = info.Addrs
 			if info.Err != nil {
-				m.recordError(target, info.Err, "dns")
+				traceError(info.Err, "dns")
 			}
 		},
 		ConnectStart: func(network, addr string) {
 			connectStart = time.Now()
+			proxy.connecting()
 		},
 		ConnectDone: func(network, addr string, err error) {
 			if !connectStart.IsZero() {
 				result.TCPTime = time.Since(connectStart).Milliseconds()
+				redirects.current.TCPTime = result.TCPTime
+			}
+			if err == nil {
+				result.RemoteAddr = addr
+				redirects.current.RemoteAddr = addr
 			}
 			if err != nil {
-				m.recordError(target, err, "connect")
+				traceError(err, "connect")
 			}
 		},
 		TLSHandshakeStart: func() {
 			tlsStart = time.Now()
+			// The handshake with the origin starts once the proxy has connected to it
+			proxy.established()
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
 			if !tlsStart.IsZero() {
 				result.TLSTime = time.Since(tlsStart).Milliseconds()
+				redirects.current.TLSTime = result.TLSTime
+			}
+			if err == nil {
+				result.TLS = newTLSInfo(state)
 			}
 			if err != nil {
-				m.recordError(target, err, "tls")
+				traceError(err, "tls")
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn = info.Conn
+			proxy.established()
+			// Reused connections skip the connect hook
+			if info.Reused {
+				result.RemoteAddr = conn.RemoteAddr().String()
 			}
 		},
 		WroteRequest: func(info httptrace.WroteRequestInfo) {
 			if info.Err != nil {
-				m.recordError(target, info.Err, "request_write")
+				traceError(info.Err, "request_write")
 			}
 			requestStart = time.Now()
 		},
 		GotFirstResponseByte: func() {
 			responseStart = time.Now()
+			redirects.firstByte()
 			if !requestStart.IsZero() {
 				result.RequestTime = time.Since(requestStart).Milliseconds()
 			}
 		},
 	}
 
-	req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+	for name, value := range target.Headers {
+		// The Host header is carried on the request rather than in its header map
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
 
-	resp, err := m.client.Do(req)
+	// Apply the target's protocol, timeout and redirect policy on a copy of the shared client
+	client := *m.client
+	client.Transport = transport
+	client.Timeout = m.timeout(target)
+	client.Jar = jar
+	client.CheckRedirect = redirects.checkRedirect
+	if target.FollowRedirects != nil && !*target.FollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	resp, err := client.Do(req)
 	result.EndTime = time.Now()
 	result.TotalTime = result.EndTime.Sub(result.StartTime).Milliseconds()
 
 	if !responseStart.IsZero() {
 		result.ResponseTime = time.Since(responseStart).Milliseconds()
 	}
+	result.Redirects = redirects.finish(resp)
+	result.Family = newFamilyInfo(targetFamily(target), resolved, result.RemoteAddr)
 
+	var body []byte
 	if err != nil {
 		result.Error = err.Error()
-		switch e := err.(type) {
-		case *net.OpError:
-			result.ErrorType = "network"
-			if e.Timeout() {
-				result.ErrorType = "timeout"
-			}
-		case net.Error:
-			if e.Timeout() {
-				result.ErrorType = "timeout"
-			} else {
-				result.ErrorType = "network"
-			}
-		case *url.Error:
-			result.ErrorType = "url"
-			if e.Timeout() {
-				result.ErrorType = "timeout"
-			}
-		default:
-			result.ErrorType = "unknown"
+		result.ErrorType = classifyError(err)
+		if errorType := classifyTLSError(err); errorType != "" {
+			result.ErrorType = errorType
 		}
+		proxy.finish(&result, err)
 	} else {
+		proxy.finish(&result, nil)
 		result.StatusCode = resp.StatusCode
+		result.Protocol = negotiatedProtocol(resp)
+		// Reused connections skip the handshake hook, so read the state from the response
+		if result.TLS == nil && resp.TLS != nil {
+			result.TLS = newTLSInfo(*resp.TLS)
+		}
+
+		// Only read the body when measuring throughput or when the caller or an assertion needs it
+		keepBody = keepBody || needsBody(target.Assertions)
+		var readErr error
+		if target.ReadBody {
+			body, result.Transfer, readErr = measureBody(resp, bodyLimit(target), keepBody, result.StartTime, responseStart)
+		} else if keepBody {
+			body, readErr = io.ReadAll(io.LimitReader(resp.Body, maxAssertionBodySize))
+		}
+		// The total time checked by assertions runs until the body has been read
+		totalTime := time.Since(result.StartTime).Milliseconds()
+
+		// Read socket statistics before the connection is returned to the pool
+		if conn != nil {
+			result.TCPInfo = connTCPInfo(conn)
+		}
 		resp.Body.Close()
+
+		if target.Assertions != nil {
+			if readErr != nil {
+				result.Assertion = &shared.AssertionResult{Failure: "failed to read body: " + readErr.Error()}
+			} else {
+				result.Assertion = evaluateAssertions(target.Assertions, resp, body, totalTime)
+			}
+		}
 	}
 
-	m.resultChan <- result
+	return result, resp, body
+}
+
+// requestMethod returns the HTTP method configured for a target
+func requestMethod(target shared.Target) string {
+	if target.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(target.Method)
+}
+
+// timeout returns the time allowed for a single probe of target
+func (m *Monitor) timeout(target shared.Target) time.Duration {
+	if target.Timeout > 0 {
+		return time.Duration(target.Timeout) * time.Millisecond
+	}
+	return m.client.Timeout
+}
+
+// classifyError maps a request error to an error type
+func classifyError(err error) string {
+	switch e := err.(type) {
+	case *net.OpError:
+		if e.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case net.Error:
+		if e.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case *url.Error:
+		if e.Timeout() {
+			return "timeout"
+		}
+		return "url"
+	default:
+		return "unknown"
+	}
 }
 
 // recordError records an error during the request process
@@ -205,12 +399,13 @@ func (m *Monitor) recordError(target shared.Target, err error, errorType string)
 	result := shared.NetworkRequest{
 		ID:         uuid.New().String(),
 		URL:        target.URL,
-		Method:     http.MethodGet,
+		Method:     requestMethod(target),
 		StartTime:  time.Now(),
 		EndTime:    time.Now(),
 		Error:      err.Error(),
 		ErrorType:  errorType,
 		TargetName: target.Name,
+		ProbeType:  shared.ProbeHTTP,
 	}
 	m.resultChan <- result
 }
\ No newline at end of file
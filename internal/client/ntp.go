@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"networkmonitor/shared"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ntpPacketSize is the size of an NTP header without extensions
+	ntpPacketSize = 48
+
+	// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and the Unix epoch
+	ntpEpochOffset = 2208988800
+
+	// ntpClientRequest sets leap indicator 0, version 4 and client mode
+	ntpClientRequest = 0x23
+)
+
+// makeNTPProbe queries a time server and records the local clock offset and round-trip delay
+func (m *Monitor) makeNTPProbe(target shared.Target) {
+	address := target.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "123")
+	}
+
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = "ntp://" + address
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeNTP
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = "config"
+		m.finishResult(&result)
+		return
+	}
+
+	family := targetFamily(target)
+	dnsStart := time.Now()
+	ip, resolved, err := resolveIP(ctx, host, family)
+	result.DNSTime = time.Since(dnsStart).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		m.finishResult(&result)
+		return
+	}
+	result.RemoteAddr = net.JoinHostPort(ip.String(), port)
+	result.Family = newFamilyInfo(family, resolved, result.RemoteAddr)
+
+	requestStart := time.Now()
+	ntp, err := queryNTP(ctx, result.RemoteAddr)
+	result.RequestTime = time.Since(requestStart).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		m.finishResult(&result)
+		return
+	}
+	result.NTP = ntp
+
+	switch {
+	case ntp.Stratum == 0:
+		result.Error = fmt.Sprintf("server sent kiss-of-death %q", ntp.ReferenceID)
+		result.ErrorType = "ntp_kiss"
+	case ntp.Leap == 3:
+		result.Error = "server clock is not synchronized"
+		result.ErrorType = "ntp_unsynchronized"
+	case target.MaxOffset > 0 && math.Abs(ntp.Offset) > float64(target.MaxOffset):
+		result.Error = fmt.Sprintf("clock offset %.1fms exceeds %dms", ntp.Offset, target.MaxOffset)
+		result.ErrorType = "clock_skew"
+	}
+
+	m.finishResult(&result)
+}
+
+// queryNTP sends a single client request to address and computes offset and delay from the reply
+func queryNTP(ctx context.Context, address string) (*shared.NTPResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := make([]byte, ntpPacketSize)
+	request[0] = ntpClientRequest
+
+	// The transmit timestamp is echoed back as the originate timestamp, which pairs the reply with this request
+	sent := time.Now()
+	binary.BigEndian.PutUint64(request[40:], toNTPTime(sent))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	reply := make([]byte, ntpPacketSize)
+	for {
+		n, err := conn.Read(reply)
+		if err != nil {
+			return nil, err
+		}
+		received := time.Now()
+
+		// Ignore short packets and replies to other requests
+		if n < ntpPacketSize || reply[0]&0x7 != 4 || binary.BigEndian.Uint64(reply[24:]) != binary.BigEndian.Uint64(request[40:]) {
+			continue
+		}
+
+		serverReceive := fromNTPTime(binary.BigEndian.Uint64(reply[32:]))
+		serverTransmit := fromNTPTime(binary.BigEndian.Uint64(reply[40:]))
+		if serverTransmit.IsZero() {
+			return nil, errors.New("server sent no transmit timestamp")
+		}
+
+		offset := (serverReceive.Sub(sent) + serverTransmit.Sub(received)) / 2
+		delay := received.Sub(sent) - serverTransmit.Sub(serverReceive)
+
+		return &shared.NTPResult{
+			Stratum:     int(reply[1]),
+			Leap:        int(reply[0] >> 6),
+			ReferenceID: ntpReferenceID(reply[1], reply[12:16]),
+			Offset:      durationMillis(offset),
+			Delay:       durationMillis(delay),
+		}, nil
+	}
+}
+
+// toNTPTime converts t to a 64-bit NTP timestamp
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// fromNTPTime converts a 64-bit NTP timestamp to a time, returning the zero time for a zero timestamp
+func fromNTPTime(ntp uint64) time.Time {
+	if ntp == 0 {
+		return time.Time{}
+	}
+	seconds := int64(ntp>>32) - ntpEpochOffset
+	nanos := (ntp & 0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanos))
+}
+
+// ntpReferenceID formats the reference identifier, which is text for stratum 0 and 1 and an address otherwise
+func ntpReferenceID(stratum byte, id []byte) string {
+	if stratum <= 1 {
+		return string(trimZeros(id))
+	}
+	return net.IP(id).String()
+}
+
+// trimZeros removes trailing zero bytes
+func trimZeros(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
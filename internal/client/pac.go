@@ -0,0 +1,337 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	// pacRefreshInterval is how long a downloaded proxy auto-config script is used before fetching it again
+	pacRefreshInterval = 5 * time.Minute
+
+	// pacCallTimeout stops scripts that loop instead of returning a proxy
+	pacCallTimeout = time.Second
+
+	// maxPACScriptSize caps the size of a proxy auto-config script
+	maxPACScriptSize = 1 << 20
+)
+
+// pacScript evaluates a proxy auto-config script. Runtimes are not safe for concurrent use,
+// so every evaluation holds the lock.
+type pacScript struct {
+	location string
+	mutex    sync.Mutex
+	runtime  *goja.Runtime
+	find     goja.Callable
+	loaded   time.Time
+	ctx      context.Context // of the evaluation in progress, used by the DNS helpers
+}
+
+// newPACScript creates an evaluator for the script at location, which is loaded on first use
+func newPACScript(location string) *pacScript {
+	return &pacScript{location: location}
+}
+
+// findProxy calls FindProxyForURL for target and returns the first proxy it names that can be used
+func (s *pacScript) findProxy(ctx context.Context, target *url.URL) (*url.URL, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ctx = ctx
+	defer func() { s.ctx = nil }()
+
+	if s.runtime == nil || time.Since(s.loaded) > pacRefreshInterval {
+		// Keep using the previous script when it cannot be refreshed
+		if err := s.load(ctx); err != nil && s.runtime == nil {
+			return nil, err
+		}
+	}
+
+	// Like browsers, hide the path and query of HTTPS URLs from the script
+	scriptURL := *target
+	if scriptURL.Scheme == "https" {
+		scriptURL.Path, scriptURL.RawPath, scriptURL.RawQuery = "/", "", ""
+	}
+
+	timer := time.AfterFunc(pacCallTimeout, func() {
+		s.runtime.Interrupt("FindProxyForURL timed out")
+	})
+	value, err := s.find(goja.Undefined(), s.runtime.ToValue(scriptURL.String()), s.runtime.ToValue(target.Hostname()))
+	timer.Stop()
+	s.runtime.ClearInterrupt()
+	if err != nil {
+		return nil, fmt.Errorf("pac script failed: %w", err)
+	}
+
+	return parsePACResult(value.String())
+}
+
+// load fetches and compiles the script
+func (s *pacScript) load(ctx context.Context) error {
+	source, err := readPACScript(ctx, s.location)
+	if err != nil {
+		return fmt.Errorf("failed to load pac script: %w", err)
+	}
+
+	runtime := goja.New()
+	s.registerHelpers(runtime)
+	if _, err := runtime.RunString(source); err != nil {
+		return fmt.Errorf("failed to run pac script: %w", err)
+	}
+
+	find, ok := goja.AssertFunction(runtime.Get("FindProxyForURL"))
+	if !ok {
+		return errors.New("pac script does not define FindProxyForURL")
+	}
+
+	s.runtime = runtime
+	s.find = find
+	s.loaded = time.Now()
+	return nil
+}
+
+// readPACScript reads a script from an HTTP URL, a file URL or a local path
+func readPACScript(ctx context.Context, location string) (string, error) {
+	if strings.HasPrefix(location, "file://") {
+		location = strings.TrimPrefix(location, "file://")
+	} else if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return "", err
+		}
+		// The script is fetched directly, since it is what decides which proxy to use
+		resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		source, err := io.ReadAll(io.LimitReader(resp.Body, maxPACScriptSize))
+		return string(source), err
+	}
+
+	source, err := os.ReadFile(location)
+	return string(source), err
+}
+
+// parsePACResult converts a FindProxyForURL result such as "PROXY a:8080; DIRECT" to the first usable proxy,
+// returning nil for DIRECT
+func parsePACResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			if len(fields) > 1 {
+				return &url.URL{Scheme: "http", Host: fields[1]}, nil
+			}
+		case "SOCKS", "SOCKS5":
+			// Go only speaks SOCKS5, so plain SOCKS entries are tried as SOCKS5
+			if len(fields) > 1 {
+				return &url.URL{Scheme: "socks5", Host: fields[1]}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("pac script returned no usable proxy in %q", result)
+}
+
+// registerHelpers defines the standard PAC functions in runtime
+func (s *pacScript) registerHelpers(runtime *goja.Runtime) {
+	runtime.Set("isPlainHostName", func(host string) bool {
+		return !strings.Contains(host, ".")
+	})
+	runtime.Set("dnsDomainIs", func(host, domain string) bool {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(domain))
+	})
+	runtime.Set("localHostOrDomainIs", func(host, hostdom string) bool {
+		host, hostdom = strings.ToLower(host), strings.ToLower(hostdom)
+		return host == hostdom || (!strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+"."))
+	})
+	runtime.Set("dnsDomainLevels", func(host string) int {
+		return strings.Count(host, ".")
+	})
+	runtime.Set("shExpMatch", func(str, pattern string) bool {
+		return shExpMatch(str, pattern)
+	})
+	runtime.Set("isResolvable", func(host string) bool {
+		return s.resolve(host) != nil
+	})
+	runtime.Set("dnsResolve", func(host string) interface{} {
+		if ip := s.resolve(host); ip != nil {
+			return ip.String()
+		}
+		return nil
+	})
+	runtime.Set("myIpAddress", func() string {
+		return localIPAddress()
+	})
+	runtime.Set("isInNet", func(host, pattern, mask string) bool {
+		ip := s.resolve(host)
+		network := net.ParseIP(pattern).To4()
+		netmask := net.ParseIP(mask).To4()
+		if ip == nil || network == nil || netmask == nil {
+			return false
+		}
+		return ip.Mask(net.IPMask(netmask)).Equal(network.Mask(net.IPMask(netmask)))
+	})
+	runtime.Set("weekdayRange", func(call goja.FunctionCall) goja.Value {
+		return runtime.ToValue(weekdayRange(pacArguments(call)))
+	})
+	runtime.Set("timeRange", func(call goja.FunctionCall) goja.Value {
+		return runtime.ToValue(timeRange(pacArguments(call)))
+	})
+}
+
+// resolve looks up the first IPv4 address of host, returning nil when it does not resolve
+func (s *pacScript) resolve(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4()
+	}
+
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil || len(addrs) == 0 {
+		return nil
+	}
+	return addrs[0].To4()
+}
+
+// localIPAddress returns the address of the interface used for outbound traffic
+func localIPAddress() string {
+	// Connecting a UDP socket selects a route without sending anything
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// shExpMatch matches str against a shell expression where * and ? are wildcards
+func shExpMatch(str, pattern string) bool {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+
+	matched, _ := regexp.MatchString(expr.String(), str)
+	return matched
+}
+
+// pacArguments converts the arguments of a PAC call to strings, reporting whether the last was "GMT"
+func pacArguments(call goja.FunctionCall) ([]string, bool) {
+	args := make([]string, 0, len(call.Arguments))
+	for _, arg := range call.Arguments {
+		args = append(args, arg.String())
+	}
+	if len(args) > 0 && strings.EqualFold(args[len(args)-1], "GMT") {
+		return args[:len(args)-1], true
+	}
+	return args, false
+}
+
+// pacNow returns the current time in UTC or local time as a PAC call asked
+func pacNow(gmt bool) time.Time {
+	if gmt {
+		return time.Now().UTC()
+	}
+	return time.Now()
+}
+
+// weekdayRange implements weekdayRange(wd1[, wd2][, "GMT"])
+func weekdayRange(args []string, gmt bool) bool {
+	days := []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+	index := func(day string) int {
+		for i, d := range days {
+			if strings.EqualFold(d, day) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if len(args) == 0 || len(args) > 2 {
+		return false
+	}
+	start := index(args[0])
+	end := start
+	if len(args) == 2 {
+		end = index(args[1])
+	}
+	if start < 0 || end < 0 {
+		return false
+	}
+
+	today := int(pacNow(gmt).Weekday())
+	if start <= end {
+		return today >= start && today <= end
+	}
+	// Ranges such as FRI to MON wrap around the week
+	return today >= start || today <= end
+}
+
+// timeRange implements the hour, minute and second forms of timeRange(...[, "GMT"])
+func timeRange(args []string, gmt bool) bool {
+	values := make([]int, len(args))
+	for i, arg := range args {
+		if _, err := fmt.Sscanf(arg, "%d", &values[i]); err != nil {
+			return false
+		}
+	}
+
+	now := pacNow(gmt)
+	seconds := func(h, m, s int) int { return h*3600 + m*60 + s }
+	current := seconds(now.Hour(), now.Minute(), now.Second())
+
+	var start, end int
+	switch len(values) {
+	case 1:
+		return now.Hour() == values[0]
+	case 2:
+		// Up to but excluding the end hour
+		start, end = seconds(values[0], 0, 0), seconds(values[1], 0, 0)-1
+	case 4:
+		start, end = seconds(values[0], values[1], 0), seconds(values[2], values[3], 0)
+	case 6:
+		start, end = seconds(values[0], values[1], values[2]), seconds(values[3], values[4], values[5])
+	default:
+		return false
+	}
+
+	if start <= end {
+		return current >= start && current <= end
+	}
+	return current >= start || current <= end
+}
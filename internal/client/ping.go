@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"networkmonitor/shared"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultPingCount is the number of echo requests sent per burst
+	defaultPingCount = 5
+
+	// defaultPingPort is the UDP port probed when ICMP is unavailable
+	defaultPingPort = 33434
+
+	pingPacketTimeout  = 2 * time.Second
+	pingPacketInterval = 200 * time.Millisecond
+)
+
+// errICMPUnsupported is returned when unprivileged ICMP echo is unavailable
+var errICMPUnsupported = errors.New("unprivileged ICMP echo is not supported on this platform")
+
+// makePingProbe sends a burst of echo requests and records latency statistics
+func (m *Monitor) makePingProbe(target shared.Target) {
+	host, port := splitPingAddress(target.Address)
+
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = "ping://" + host
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbePing
+	result.Scope = target.Scope
+
+	count := target.Count
+	if count <= 0 {
+		count = defaultPingCount
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	dnsStart := time.Now()
+	ip, resolved, err := resolveIP(ctx, host, targetFamily(target))
+	result.DNSTime = time.Since(dnsStart).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		m.finishResult(&result)
+		return
+	}
+	result.RemoteAddr = ip.String()
+	result.Family = newFamilyInfo(targetFamily(target), resolved, result.RemoteAddr)
+
+	// Prefer ICMP echo and fall back to UDP where the platform reports refused datagrams
+	method := "icmp"
+	rtts, err := pingICMP(ip, count)
+	if err != nil && udpPingFallback {
+		method = "udp"
+		rtts, err = pingUDP(ip, port, count)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		if errors.Is(err, errICMPUnsupported) {
+			result.ErrorType = "unsupported"
+		}
+		m.finishResult(&result)
+		return
+	}
+
+	result.Ping = newPingStats(method, count, rtts)
+	if result.Ping.PacketsReceived == 0 {
+		result.Error = fmt.Sprintf("no replies from %s (%d packets sent)", host, count)
+		result.ErrorType = "timeout"
+	}
+
+	m.finishResult(&result)
+}
+
+// finishResult stamps the end time on a result and sends it
+func (m *Monitor) finishResult(result *shared.NetworkRequest) {
+	result.EndTime = time.Now()
+	result.TotalTime = result.EndTime.Sub(result.StartTime).Milliseconds()
+	m.resultChan <- *result
+}
+
+// splitPingAddress splits an optional port off a ping address
+func splitPingAddress(address string) (string, int) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, defaultPingPort
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 {
+		port = defaultPingPort
+	}
+
+	return host, port
+}
+
+// pingUDP sends count UDP datagrams and treats any reply or port-unreachable error as a response
+func pingUDP(ip net.IP, port, count int) ([]time.Duration, error) {
+	dst := &net.UDPAddr{IP: ip, Port: port}
+	buf := make([]byte, 1500)
+	rtts := make([]time.Duration, 0, count)
+
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(pingPacketInterval)
+		}
+
+		// A fresh socket per packet keeps late ICMP errors from being attributed to the next one
+		conn, err := net.DialUDP("udp", nil, dst)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		if _, err := conn.Write([]byte("networkmonitor")); err != nil && !errors.Is(err, syscall.ECONNREFUSED) {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetReadDeadline(start.Add(pingPacketTimeout))
+
+		_, err = conn.Read(buf)
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			rtts = append(rtts, time.Since(start))
+		}
+		conn.Close()
+	}
+
+	return rtts, nil
+}
+
+// newPingStats summarises the round-trip times of a ping burst
+func newPingStats(method string, sent int, rtts []time.Duration) *shared.PingStats {
+	stats := &shared.PingStats{
+		Method:          method,
+		PacketsSent:     sent,
+		PacketsReceived: len(rtts),
+	}
+	if sent > 0 {
+		stats.PacketLoss = float64(sent-len(rtts)) / float64(sent) * 100
+	}
+	if len(rtts) == 0 {
+		return stats
+	}
+
+	var sum, jitter float64
+	stats.MinRTT = math.MaxFloat64
+	for i, rtt := range rtts {
+		ms := durationMillis(rtt)
+		sum += ms
+		stats.MinRTT = math.Min(stats.MinRTT, ms)
+		stats.MaxRTT = math.Max(stats.MaxRTT, ms)
+		if i > 0 {
+			jitter += math.Abs(ms - durationMillis(rtts[i-1]))
+		}
+	}
+	stats.AvgRTT = sum / float64(len(rtts))
+	if len(rtts) > 1 {
+		stats.Jitter = jitter / float64(len(rtts)-1)
+	}
+
+	var variance float64
+	for _, rtt := range rtts {
+		d := durationMillis(rtt) - stats.AvgRTT
+		variance += d * d
+	}
+	stats.StdDevRTT = math.Sqrt(variance / float64(len(rtts)))
+
+	return stats
+}
+
+// durationMillis converts a duration to fractional milliseconds
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package client
+
+import (
+	"net"
+
+	"golang.org/x/net/icmp"
+)
+
+// listenICMP opens an unprivileged ICMP datagram socket for the address family of ip
+func listenICMP(ip net.IP) (*icmp.PacketConn, error) {
+	if ip.To4() != nil {
+		return icmp.ListenPacket("udp4", "0.0.0.0")
+	}
+	return icmp.ListenPacket("udp6", "::")
+}
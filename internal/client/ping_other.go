@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package client
+
+import (
+	"net"
+
+	"golang.org/x/net/icmp"
+)
+
+// listenICMP reports that unprivileged ICMP sockets are unavailable so callers fall back to UDP
+func listenICMP(ip net.IP) (*icmp.PacketConn, error) {
+	return nil, errICMPUnsupported
+}
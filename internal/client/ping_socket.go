@@ -0,0 +1,76 @@
+//go:build !windows
+
+package client
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// udpPingFallback is set because these platforms report ICMP port unreachable to UDP sockets
+const udpPingFallback = true
+
+// pingICMP sends count ICMP echo requests and returns the round-trip times of the replies
+func pingICMP(ip net.IP, count int) ([]time.Duration, error) {
+	conn, err := listenICMP(ip)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	protocol := 1 // ICMPv4
+	if ip.To4() == nil {
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		protocol = 58 // ICMPv6
+	}
+
+	dst := &net.UDPAddr{IP: ip}
+	buf := make([]byte, 1500)
+	rtts := make([]time.Duration, 0, count)
+
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(pingPacketInterval)
+		}
+
+		// The kernel rewrites the echo ID on datagram sockets, so replies are matched by sequence
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: 0, Seq: seq, Data: []byte("networkmonitor")},
+		}
+		data, err := msg.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(data, dst); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(start.Add(pingPacketTimeout))
+
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				// Deadline reached, count the packet as lost
+				break
+			}
+
+			reply, err := icmp.ParseMessage(protocol, buf[:n])
+			if err != nil || reply.Type != replyType {
+				continue
+			}
+			if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq {
+				rtts = append(rtts, time.Since(start))
+				break
+			}
+		}
+	}
+
+	return rtts, nil
+}
@@ -0,0 +1,121 @@
+//go:build windows
+
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// udpPingFallback is unset because Windows hides ICMP port unreachable from UDP sockets
+// (SIO_UDP_CONNRESET), so a UDP ping would report every packet as lost
+const udpPingFallback = false
+
+var (
+	iphlpapi            = windows.NewLazySystemDLL("iphlpapi.dll")
+	procIcmpCreateFile  = iphlpapi.NewProc("IcmpCreateFile")
+	procIcmp6CreateFile = iphlpapi.NewProc("Icmp6CreateFile")
+	procIcmpCloseHandle = iphlpapi.NewProc("IcmpCloseHandle")
+	procIcmpSendEcho    = iphlpapi.NewProc("IcmpSendEcho")
+	procIcmp6SendEcho2  = iphlpapi.NewProc("Icmp6SendEcho2")
+)
+
+const (
+	// ipStatusBase starts the IP_STATUS codes the ICMP API fails with when no echo reply arrives
+	ipStatusBase = 11000
+	ipStatusMax  = 11050
+
+	// icmp6ReplyStatusOffset is the offset of Status in ICMPV6_ECHO_REPLY, after a packed IPV6_ADDRESS_EX
+	icmp6ReplyStatusOffset = 28
+)
+
+// icmpEchoReply mirrors ICMP_ECHO_REPLY
+type icmpEchoReply struct {
+	Address       uint32
+	Status        uint32
+	RoundTripTime uint32
+	DataSize      uint16
+	Reserved      uint16
+	Data          uintptr
+	Options       ipOptionInformation
+}
+
+// ipOptionInformation mirrors IP_OPTION_INFORMATION
+type ipOptionInformation struct {
+	TTL         uint8
+	TOS         uint8
+	Flags       uint8
+	OptionsSize uint8
+	OptionsData uintptr
+}
+
+// pingICMP sends count echo requests through the Windows ICMP API, which needs no privileges,
+// and returns the round-trip times of the replies
+func pingICMP(ip net.IP, count int) ([]time.Duration, error) {
+	ip4 := ip.To4()
+
+	create := procIcmpCreateFile
+	if ip4 == nil {
+		create = procIcmp6CreateFile
+	}
+	if err := create.Find(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errICMPUnsupported, err)
+	}
+	handle, _, err := create.Call()
+	if windows.Handle(handle) == windows.InvalidHandle {
+		return nil, fmt.Errorf("%w: %v", errICMPUnsupported, err)
+	}
+	defer procIcmpCloseHandle.Call(handle)
+
+	data := []byte("networkmonitor")
+	// The reply buffer holds the reply header, the echoed data and room for an ICMP error message
+	reply := make([]byte, unsafe.Sizeof(icmpEchoReply{})+uintptr(len(data))+8+8)
+	timeout := uintptr(pingPacketTimeout.Milliseconds())
+	rtts := make([]time.Duration, 0, count)
+
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(pingPacketInterval)
+		}
+
+		start := time.Now()
+		var replies uintptr
+		var status uint32
+		if ip4 != nil {
+			replies, _, err = procIcmpSendEcho.Call(handle,
+				uintptr(binary.LittleEndian.Uint32(ip4)),
+				uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)),
+				0, uintptr(unsafe.Pointer(&reply[0])), uintptr(len(reply)), timeout)
+			status = (*icmpEchoReply)(unsafe.Pointer(&reply[0])).Status
+		} else {
+			source := windows.RawSockaddrInet6{Family: windows.AF_INET6}
+			destination := windows.RawSockaddrInet6{Family: windows.AF_INET6}
+			copy(destination.Addr[:], ip.To16())
+			replies, _, err = procIcmp6SendEcho2.Call(handle, 0, 0, 0,
+				uintptr(unsafe.Pointer(&source)), uintptr(unsafe.Pointer(&destination)),
+				uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)),
+				0, uintptr(unsafe.Pointer(&reply[0])), uintptr(len(reply)), timeout)
+			status = binary.LittleEndian.Uint32(reply[icmp6ReplyStatusOffset:])
+		}
+		elapsed := time.Since(start)
+
+		if replies == 0 {
+			// Timeouts and unreachable destinations count the packet as lost, anything else is a failure
+			if errno, ok := err.(syscall.Errno); ok && errno >= ipStatusBase && errno <= ipStatusMax {
+				continue
+			}
+			return nil, err
+		}
+		if status == 0 {
+			rtts = append(rtts, elapsed)
+		}
+	}
+
+	return rtts, nil
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"networkmonitor/shared"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPortalURL answers 204 with no content, which captive portals replace with their login page
+	defaultPortalURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+	// defaultPortalInterval is how often the check runs when the configuration does not say
+	defaultPortalInterval = 60 * time.Second
+
+	// portalTimeout bounds a single check
+	portalTimeout = 10 * time.Second
+
+	// maxPortalBodySize caps how much of the response is compared with the expected content
+	maxPortalBodySize = 64 << 10
+)
+
+// portalCheck returns config with its defaults applied
+func portalCheck(config *shared.PortalCheckConfig) shared.PortalCheckConfig {
+	var check shared.PortalCheckConfig
+	if config != nil {
+		check = *config
+	}
+	if check.URL == "" {
+		check.URL = defaultPortalURL
+	}
+	if check.ExpectedStatus == 0 {
+		check.ExpectedStatus = http.StatusNoContent
+		if check.ExpectedBody != "" {
+			check.ExpectedStatus = http.StatusOK
+		}
+	}
+	return check
+}
+
+// portalInterval returns how often the captive portal check runs for config
+func portalInterval(config *shared.PortalCheckConfig) time.Duration {
+	if config != nil && config.Interval > 0 {
+		return time.Duration(config.Interval) * time.Second
+	}
+	return defaultPortalInterval
+}
+
+// checkNetworkState fetches the known-content URL and reports whether the network tampered with it
+func checkNetworkState(check shared.PortalCheckConfig) shared.NetworkState {
+	state := shared.NetworkState{
+		CheckedAt: time.Now(),
+		State:     shared.NetworkStateOpen,
+		URL:       check.URL,
+	}
+
+	// The check goes direct and stops at the first response, since a redirect is what gives a portal away
+	client := &http.Client{
+		Timeout:   portalTimeout,
+		Transport: &http.Transport{DisableKeepAlives: true},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, check.URL, nil)
+	if err != nil {
+		state.State = shared.NetworkStateOffline
+		state.Detail = err.Error()
+		return state
+	}
+	// Keep caches on the path from answering for the check URL
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		state.Detail = err.Error()
+		switch classifyTLSError(err) {
+		case "tls_unknown_authority", "tls_hostname_mismatch", "tls_invalid_certificate", "tls_expired":
+			state.State = shared.NetworkStateIntercepted
+		case "tls_not_tls":
+			// Something answered in place of the HTTPS server
+			state.State = shared.NetworkStateTampered
+		default:
+			state.State = shared.NetworkStateOffline
+		}
+		return state
+	}
+	defer resp.Body.Close()
+
+	state.StatusCode = resp.StatusCode
+	state.Via = resp.Header.Get("Via")
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		state.Issuer = resp.TLS.PeerCertificates[0].Issuer.String()
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPortalBodySize))
+	if err != nil {
+		state.State = shared.NetworkStateOffline
+		state.Detail = "failed to read body: " + err.Error()
+		return state
+	}
+
+	switch {
+	case check.ExpectedIssuer != "" && state.Issuer != "" && !strings.Contains(state.Issuer, check.ExpectedIssuer):
+		// A trusted but unexpected issuer is an intercepting proxy whose CA was installed on the machine
+		state.State = shared.NetworkStateIntercepted
+		state.Detail = fmt.Sprintf("certificate issued by %q", state.Issuer)
+	case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.StatusCode != check.ExpectedStatus:
+		state.State = shared.NetworkStateCaptivePortal
+		state.RedirectURL = resp.Header.Get("Location")
+		state.Detail = fmt.Sprintf("redirected to %q", state.RedirectURL)
+	case resp.StatusCode != check.ExpectedStatus:
+		// Portals that do not redirect answer with their login page or 511 instead
+		state.State = shared.NetworkStateCaptivePortal
+		state.Detail = fmt.Sprintf("expected status %d, got %d", check.ExpectedStatus, resp.StatusCode)
+	case strings.TrimSpace(string(body)) != strings.TrimSpace(check.ExpectedBody):
+		state.State = shared.NetworkStateTampered
+		state.Detail = "content differs from the expected content"
+	}
+
+	return state
+}
+
+// checkPortal periodically runs the captive portal check, keeping the state for tagging results
+// and sending it to the server
+func (c *Client) checkPortal() {
+	for {
+		// The configuration is read each time so updates apply to the next check
+		config := c.currentConfig().PortalCheck
+		if config == nil || !config.Disabled {
+			state := checkNetworkState(portalCheck(config))
+			if previous := c.setNetworkState(state.State); previous != state.State {
+				fmt.Printf("Network state: %s %s\n", state.State, state.Detail)
+			}
+			if c.connection.IsConnected() {
+				c.connection.SendMessage(shared.TypeNetworkState, state)
+			}
+		} else {
+			c.setNetworkState("")
+		}
+
+		select {
+		case <-c.stopChan:
+			return
+		case <-time.After(portalInterval(config)):
+		}
+	}
+}
+
+// setNetworkState records the latest network state and returns the previous one
+func (c *Client) setNetworkState(state string) string {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	previous := c.networkState
+	c.networkState = state
+	return previous
+}
+
+// currentNetworkState returns the latest network state, empty when not yet checked
+func (c *Client) currentNetworkState() string {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	return c.networkState
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"networkmonitor/shared"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// newHTTPTransport creates a transport that negotiates HTTP/1.1 or HTTP/2 and dials over family
+func (m *Monitor) newHTTPTransport(family string, tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		DialContext:       m.familyDialer(family),
+		DisableKeepAlives: false,
+		// A custom dialer disables HTTP/2 unless it is requested explicitly
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   tlsConfig,
+	}
+}
+
+// transportFor returns the round tripper that speaks the protocol and address family forced by target,
+// goes through its proxy and uses its TLS settings
+func (m *Monitor) transportFor(target shared.Target) (http.RoundTripper, error) {
+	protocol := strings.ToLower(target.Protocol)
+	family := targetFamily(target)
+	if protocol == "" && family == "" && !usesProxy(target) && target.TLS == nil {
+		return m.client.Transport, nil
+	}
+	if family != "" && family != shared.FamilyIPv4 && family != shared.FamilyIPv6 {
+		return nil, fmt.Errorf("unsupported address family %q", target.AddressFamily)
+	}
+
+	m.transportMutex.Lock()
+	defer m.transportMutex.Unlock()
+
+	// Transports are shared between probes so connections are pooled per protocol, family, proxy and TLS settings
+	key := protocol + "/" + family + "/" + proxyKey(target) + "/" + tlsKey(target)
+	if transport, found := m.transports[key]; found {
+		return transport, nil
+	}
+
+	tlsConfig, err := targetTLSConfig(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var transport http.RoundTripper
+	switch protocol {
+	case "":
+		transport = m.newHTTPTransport(family, tlsConfig)
+	case shared.ProtocolHTTP1:
+		http1 := m.newHTTPTransport(family, tlsConfig)
+		http1.ForceAttemptHTTP2 = false
+		// A non-nil empty map stops the transport from offering HTTP/2
+		http1.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		transport = http1
+	case shared.ProtocolHTTP2:
+		transport = &http2.Transport{
+			DialTLSContext: func(ctx context.Context, network, address string, config *tls.Config) (net.Conn, error) {
+				return m.dialTLS(ctx, familyNetwork(network, family), address, config)
+			},
+			TLSClientConfig: tlsConfig,
+		}
+	case shared.ProtocolHTTP3:
+		transport = &http3Transport{&http3.RoundTripper{
+			TLSClientConfig: tlsConfig,
+			Dial: func(ctx context.Context, address string, config *tls.Config, quicConfig *quic.Config) (quic.EarlyConnection, error) {
+				return dialQUIC(ctx, address, family, config, quicConfig)
+			},
+		}}
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", target.Protocol)
+	}
+
+	if usesProxy(target) {
+		// Only the standard transport knows how to talk to proxies
+		httpTransport, ok := transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("protocol %q cannot be used through a proxy", target.Protocol)
+		}
+		selector, err := newProxySelector(target.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		httpTransport.Proxy = proxyFunc(selector)
+		httpTransport.OnProxyConnectResponse = onProxyConnectResponse
+	}
+
+	m.transports[key] = transport
+	return transport, nil
+}
+
+// dialTLS dials a TLS connection for the HTTP/2 transport, which does not report handshakes itself
+func (m *Monitor) dialTLS(ctx context.Context, network, address string, config *tls.Config) (net.Conn, error) {
+	conn, err := m.dialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.HandshakeContext(ctx)
+
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(tlsConn.ConnectionState(), err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// http3Transport reports the request timings that quic-go's round tripper does not trace
+type http3Transport struct {
+	*http3.RoundTripper
+}
+
+// RoundTrip sends req over HTTP/3, reporting when the request was sent and its response arrived
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := httptrace.ContextClientTrace(req.Context())
+	if trace == nil || trace.WroteRequest == nil || trace.GotFirstResponseByte == nil {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	// A pooled connection sends the request at once, a new one as soon as dialQUIC completes its handshake
+	trace.WroteRequest(httptrace.WroteRequestInfo{})
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				trace.WroteRequest(httptrace.WroteRequestInfo{})
+			}
+		},
+	})
+
+	// The round tripper returns once the response headers are in
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err == nil {
+		trace.GotFirstResponseByte()
+	}
+	return resp, err
+}
+
+// dialQUIC opens a QUIC connection over family for the HTTP/3 transport, reporting the lookup and
+// handshake to the request trace. QUIC has no separate connect phase, so its whole handshake is
+// reported as TLS time.
+func dialQUIC(ctx context.Context, address, family string, config *tls.Config, quicConfig *quic.Config) (quic.EarlyConnection, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	// quic-go resolves without tracing, so the lookup is done here with the request's context,
+	// whose trace the resolver reports DNSStart and DNSDone to
+	ip, _, err := resolveIP(ctx, host, family)
+	if err != nil {
+		return nil, err
+	}
+	address = net.JoinHostPort(ip.String(), port)
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("udp", address)
+	}
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("udp", address, nil)
+	}
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+
+	conn, err := quic.DialAddrEarly(ctx, address, config, quicConfig)
+	if err == nil {
+		select {
+		case <-conn.HandshakeComplete():
+		case <-ctx.Done():
+			err = ctx.Err()
+			conn.CloseWithError(0, "")
+		}
+	}
+
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		var state tls.ConnectionState
+		if err == nil {
+			state = conn.ConnectionState().TLS
+		}
+		trace.TLSHandshakeDone(state, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// negotiatedProtocol converts a response's protocol version to its protocol constant
+func negotiatedProtocol(resp *http.Response) string {
+	switch resp.ProtoMajor {
+	case 1:
+		return shared.ProtocolHTTP1
+	case 2:
+		return shared.ProtocolHTTP2
+	case 3:
+		return shared.ProtocolHTTP3
+	default:
+		return resp.Proto
+	}
+}
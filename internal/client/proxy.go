@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"networkmonitor/shared"
+	"strings"
+	"time"
+)
+
+// proxyContextKey carries a request's proxyRecorder to the transport hooks that have no request trace
+type proxyContextKey struct{}
+
+// proxySelector picks the proxy for a request, returning a nil URL to connect directly
+type proxySelector func(req *http.Request) (*url.URL, string, error)
+
+// applyDefaultProxy returns a copy of targets where those without their own proxy use proxy
+func applyDefaultProxy(targets []shared.Target, proxy *shared.ProxyConfig) []shared.Target {
+	if proxy == nil {
+		return targets
+	}
+
+	applied := make([]shared.Target, len(targets))
+	for i, target := range targets {
+		if target.Proxy == nil {
+			target.Proxy = proxy
+		}
+		applied[i] = target
+	}
+	return applied
+}
+
+// usesProxy reports whether target may send its requests through a proxy
+func usesProxy(target shared.Target) bool {
+	return target.Proxy != nil && target.Proxy.Mode != "" && target.Proxy.Mode != shared.ProxyDirect
+}
+
+// proxyKey identifies a proxy configuration when caching transports
+func proxyKey(target shared.Target) string {
+	if !usesProxy(target) {
+		return ""
+	}
+	return target.Proxy.Mode + " " + target.Proxy.URL
+}
+
+// newProxySelector creates the selector for a proxy configuration
+func newProxySelector(config *shared.ProxyConfig) (proxySelector, error) {
+	switch config.Mode {
+	case shared.ProxyManual:
+		proxyURL, err := parseProxyURL(config.URL)
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request) (*url.URL, string, error) {
+			return proxyURL, shared.ProxyManual, nil
+		}, nil
+	case shared.ProxyEnvironment:
+		return func(req *http.Request) (*url.URL, string, error) {
+			proxyURL, err := http.ProxyFromEnvironment(req)
+			return proxyURL, shared.ProxyEnvironment, err
+		}, nil
+	case shared.ProxyPAC:
+		if config.URL == "" {
+			return nil, errors.New("pac proxy needs the script location in url")
+		}
+		script := newPACScript(config.URL)
+		return func(req *http.Request) (*url.URL, string, error) {
+			proxyURL, err := script.findProxy(req.Context(), req.URL)
+			return proxyURL, shared.ProxyPAC, err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy mode %q", config.Mode)
+	}
+}
+
+// parseProxyURL parses a proxy address, which must be an HTTP or SOCKS5 proxy
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "socks5" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy url %q has no host", raw)
+	}
+	return proxyURL, nil
+}
+
+// proxyFunc adapts a selector to http.Transport, noting the chosen proxy on the request's recorder
+func proxyFunc(selector proxySelector) func(req *http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, source, err := selector(req)
+		recorder, _ := req.Context().Value(proxyContextKey{}).(*proxyRecorder)
+		recorder.selected(proxyURL, source, err)
+		return proxyURL, err
+	}
+}
+
+// onProxyConnectResponse notes the reply to a CONNECT request on the request's recorder
+func onProxyConnectResponse(ctx context.Context, proxyURL *url.URL, connectReq *http.Request, connectRes *http.Response) error {
+	recorder, _ := ctx.Value(proxyContextKey{}).(*proxyRecorder)
+	recorder.tunnel(connectRes.StatusCode)
+	return nil
+}
+
+// proxyRecorder follows one HTTP request through its proxy.
+// A nil recorder ignores every call so the request hooks can use it unconditionally.
+type proxyRecorder struct {
+	result       *shared.ProxyResult
+	selectErr    error
+	connectStart time.Time
+	ready        bool // the proxy connected the request to the origin
+}
+
+// selected records the proxy chosen for the request, if any
+func (r *proxyRecorder) selected(proxyURL *url.URL, source string, err error) {
+	if r == nil {
+		return
+	}
+	r.selectErr = err
+	switch {
+	case err != nil:
+		r.result = &shared.ProxyResult{Source: source}
+	case proxyURL == nil:
+		r.result = nil
+	default:
+		r.result = &shared.ProxyResult{URL: proxyURL.Redacted(), Source: source}
+	}
+}
+
+// connecting marks the start of the connection to the proxy
+func (r *proxyRecorder) connecting() {
+	if r == nil || r.result == nil {
+		return
+	}
+	r.connectStart = time.Now()
+}
+
+// tunnel records the proxy's reply to a CONNECT request
+func (r *proxyRecorder) tunnel(status int) {
+	if r == nil || r.result == nil {
+		return
+	}
+	r.result.Status = status
+	if status == http.StatusOK {
+		r.established()
+	}
+}
+
+// established marks the proxy as ready to carry the request to the origin
+func (r *proxyRecorder) established() {
+	if r == nil || r.result == nil || r.ready {
+		return
+	}
+	r.ready = true
+	if !r.connectStart.IsZero() {
+		r.result.ConnectTime = time.Since(r.connectStart).Milliseconds()
+	}
+}
+
+// finish attaches the proxy details to result and attributes a failed request to the proxy or the origin
+func (r *proxyRecorder) finish(result *shared.NetworkRequest, err error) {
+	if r == nil || r.result == nil {
+		return
+	}
+	result.Proxy = r.result
+	if err == nil {
+		return
+	}
+
+	switch {
+	case r.selectErr != nil:
+		r.result.FailedAt = "proxy"
+		result.ErrorType = "proxy_config"
+	case r.ready:
+		r.result.FailedAt = "origin"
+	case r.result.Status != 0:
+		// The proxy answered the CONNECT request, so its status says which side refused
+		switch r.result.Status {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			r.result.FailedAt = "origin"
+		case http.StatusProxyAuthRequired:
+			r.result.FailedAt = "proxy"
+			result.ErrorType = "proxy_auth"
+		default:
+			r.result.FailedAt = "proxy"
+			result.ErrorType = "proxy"
+		}
+	case socksOriginFailure(err):
+		r.result.FailedAt = "origin"
+	default:
+		r.result.FailedAt = "proxy"
+		if result.ErrorType != "timeout" {
+			result.ErrorType = "proxy"
+		}
+	}
+}
+
+// socksOriginFailure reports whether a SOCKS5 proxy refused the request because it could not reach the origin
+func socksOriginFailure(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "socks connect" || opErr.Err == nil {
+		return false
+	}
+
+	// The transport only exposes the reply code through its text
+	message := opErr.Err.Error()
+	for _, reply := range []string{"network unreachable", "host unreachable", "connection refused", "TTL expired"} {
+		if strings.HasSuffix(message, reply) {
+			return true
+		}
+	}
+	return false
+}
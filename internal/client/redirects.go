@@ -0,0 +1,62 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"networkmonitor/shared"
+	"time"
+)
+
+// maxRedirects matches the limit applied by http.Client's default redirect policy
+const maxRedirects = 10
+
+// redirectRecorder collects the hops of a redirect chain as the client follows it
+type redirectRecorder struct {
+	hops     []shared.RedirectHop
+	current  shared.RedirectHop
+	hopStart time.Time
+}
+
+// newRedirectRecorder starts recording a chain at url
+func newRedirectRecorder(url string) *redirectRecorder {
+	return &redirectRecorder{
+		current:  shared.RedirectHop{URL: url},
+		hopStart: time.Now(),
+	}
+}
+
+// checkRedirect is an http.Client CheckRedirect hook that closes the current hop before following req
+func (r *redirectRecorder) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	r.closeHop(req.Response)
+	r.current = shared.RedirectHop{URL: req.URL.String()}
+	r.hopStart = time.Now()
+	return nil
+}
+
+// firstByte records the time to first byte of the current hop
+func (r *redirectRecorder) firstByte() {
+	r.current.TTFB = time.Since(r.hopStart).Milliseconds()
+}
+
+// finish closes the final hop and returns the chain, or nil when no redirect was followed
+func (r *redirectRecorder) finish(resp *http.Response) []shared.RedirectHop {
+	if len(r.hops) == 0 {
+		return nil
+	}
+	r.closeHop(resp)
+	return r.hops
+}
+
+// closeHop completes the current hop with its response and appends it to the chain
+func (r *redirectRecorder) closeHop(resp *http.Response) {
+	if resp != nil {
+		r.current.StatusCode = resp.StatusCode
+		r.current.Location = resp.Header.Get("Location")
+	}
+	r.current.TotalTime = time.Since(r.hopStart).Milliseconds()
+	r.hops = append(r.hops, r.current)
+}
@@ -0,0 +1,423 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSpoolMaxSize bounds the spool when the configuration does not say
+	defaultSpoolMaxSize = 50 << 20
+
+	// defaultSpoolMaxAge is how long spooled messages are kept when the configuration does not say
+	defaultSpoolMaxAge = 7 * 24 * time.Hour
+
+	// spoolSegmentSize is the size at which a new segment file is started, so the oldest
+	// messages can be dropped or replayed without rewriting the whole spool
+	spoolSegmentSize = 1 << 20
+)
+
+// spoolEntry is a message waiting in the spool
+type spoolEntry struct {
+	SpooledAt time.Time       `json:"spooledAt"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Spool keeps messages on disk while they cannot be sent to the server.
+// Messages are stored in order in numbered segment files, one JSON entry per line.
+type Spool struct {
+	dir      string
+	maxSize  int64
+	maxAge   time.Duration
+	mutex    sync.Mutex
+	active   *os.File // segment being appended to, nil when the next message starts a new one
+	activeNo int64
+	sizes    map[int64]int64 // bytes in each segment
+	counts   map[int64]int   // messages in each segment
+	pending  int
+	dropped  int64
+}
+
+// NewSpool opens the spool in dir, picking up messages left by a previous run
+func NewSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		dir:     dir,
+		maxSize: defaultSpoolMaxSize,
+		maxAge:  defaultSpoolMaxAge,
+		sizes:   make(map[int64]int64),
+		counts:  make(map[int64]int),
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, no := range segments {
+		info, err := os.Stat(s.segmentPath(no))
+		if err != nil {
+			continue
+		}
+		entries, err := readSpoolSegment(s.segmentPath(no))
+		if err != nil {
+			continue
+		}
+		s.sizes[no] = info.Size()
+		s.counts[no] = len(entries)
+		s.pending += len(entries)
+		s.activeNo = no
+	}
+
+	return s, nil
+}
+
+// SetLimits changes the size and age limits, using the defaults for values that are not positive
+func (s *Spool) SetLimits(maxSize int64, maxAge time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.maxSize = maxSize
+	if s.maxSize <= 0 {
+		s.maxSize = defaultSpoolMaxSize
+	}
+	s.maxAge = maxAge
+	if s.maxAge <= 0 {
+		s.maxAge = defaultSpoolMaxAge
+	}
+}
+
+// Add appends a message to the spool, dropping the oldest segments when it grows past its size limit
+func (s *Spool) Add(msgType string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		s.drop(1)
+		return err
+	}
+	line, err := json.Marshal(spoolEntry{SpooledAt: time.Now(), Type: msgType, Data: raw})
+	if err != nil {
+		s.drop(1)
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.active == nil || s.sizes[s.activeNo] >= spoolSegmentSize {
+		if s.active != nil {
+			s.active.Close()
+		}
+		s.activeNo++
+		s.active, err = os.OpenFile(s.segmentPath(s.activeNo), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			s.active = nil
+			s.dropped++
+			return fmt.Errorf("failed to create spool segment: %w", err)
+		}
+	}
+
+	if _, err := s.active.Write(line); err != nil {
+		s.dropped++
+		return fmt.Errorf("failed to write to spool: %w", err)
+	}
+	s.sizes[s.activeNo] += int64(len(line))
+	s.counts[s.activeNo]++
+	s.pending++
+
+	s.enforceSize()
+	return nil
+}
+
+// enforceSize removes the oldest segments while the spool is larger than its limit.
+// The segment being appended to is always kept.
+func (s *Spool) enforceSize() {
+	var total int64
+	for _, size := range s.sizes {
+		total += size
+	}
+
+	for total > s.maxSize {
+		oldest := s.oldest()
+		if oldest == s.activeNo {
+			return
+		}
+		total -= s.sizes[oldest]
+		s.dropped += int64(s.counts[oldest])
+		s.removeSegment(oldest)
+	}
+}
+
+// Next returns the oldest spooled messages, skipping and dropping those past the age limit.
+// The batch stays in the spool until Consume is called for it.
+func (s *Spool) Next() (int64, []spoolEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.sizes) > 0 {
+		no := s.oldest()
+		// Stop appending to the segment being replayed, so later messages go to a new one
+		if no == s.activeNo && s.active != nil {
+			s.active.Close()
+			s.active = nil
+		}
+
+		entries, err := readSpoolSegment(s.segmentPath(no))
+		if err != nil {
+			// An unreadable segment would block the spool forever
+			s.dropped += int64(s.counts[no])
+			s.removeSegment(no)
+			return 0, nil, err
+		}
+
+		fresh := entries[:0]
+		for _, entry := range entries {
+			if time.Since(entry.SpooledAt) <= s.maxAge {
+				fresh = append(fresh, entry)
+			}
+		}
+		if expired := len(entries) - len(fresh); expired > 0 {
+			s.dropped += int64(expired)
+			if err := s.rewriteSegment(no, fresh); err != nil {
+				return 0, nil, err
+			}
+		}
+		if len(fresh) == 0 {
+			s.removeSegment(no)
+			continue
+		}
+		return no, fresh, nil
+	}
+
+	return 0, nil, nil
+}
+
+// Consume removes the first n messages of a batch returned by Next once they were handed to the connection
+func (s *Spool) Consume(no int64, entries []spoolEntry, n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The segment may have been dropped for size while it was replayed
+	if _, found := s.sizes[no]; !found {
+		return nil
+	}
+	if n >= len(entries) {
+		s.removeSegment(no)
+		return nil
+	}
+	return s.rewriteSegment(no, entries[n:])
+}
+
+// Pending returns the number of messages waiting in the spool
+func (s *Spool) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pending
+}
+
+// Dropped returns the number of messages discarded since the client started
+func (s *Spool) Dropped() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// drop counts messages that were discarded before reaching the spool
+func (s *Spool) drop(n int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.dropped += n
+}
+
+// Close closes the segment being appended to
+func (s *Spool) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+}
+
+// rewriteSegment replaces a segment that is no longer appended to with the given entries
+func (s *Spool) rewriteSegment(no int64, entries []spoolEntry) error {
+	if len(entries) == 0 {
+		s.removeSegment(no)
+		return nil
+	}
+
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	// Write a temporary file first so a crash leaves either the old or the new segment
+	tmpPath := s.segmentPath(no) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.segmentPath(no)); err != nil {
+		return err
+	}
+
+	s.pending -= s.counts[no] - len(entries)
+	s.sizes[no] = int64(len(data))
+	s.counts[no] = len(entries)
+	return nil
+}
+
+// removeSegment deletes a segment and forgets its messages
+func (s *Spool) removeSegment(no int64) {
+	if no == s.activeNo && s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+	os.Remove(s.segmentPath(no))
+	s.pending -= s.counts[no]
+	delete(s.sizes, no)
+	delete(s.counts, no)
+}
+
+// oldest returns the number of the oldest segment
+func (s *Spool) oldest() int64 {
+	oldest := s.activeNo
+	for no := range s.sizes {
+		if no < oldest {
+			oldest = no
+		}
+	}
+	return oldest
+}
+
+// segments lists the segment numbers in the spool directory, oldest first
+func (s *Spool) segments() ([]int64, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int64
+	for _, file := range files {
+		var no int64
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl") {
+			continue
+		}
+		if _, err := fmt.Sscanf(file.Name(), "%d.jsonl", &no); err == nil {
+			segments = append(segments, no)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// segmentPath returns the file holding a segment
+func (s *Spool) segmentPath(no int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d.jsonl", no))
+}
+
+// readSpoolSegment reads the entries of a segment, skipping lines cut short by a crash
+func readSpoolSegment(path string) ([]spoolEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []spoolEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), spoolSegmentSize*2)
+	for scanner.Scan() {
+		var entry spoolEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+const (
+	// spoolReplayInterval is how often the spool is checked for messages to replay
+	spoolReplayInterval = 5 * time.Second
+
+	// spoolQueueTimeout is how long replay waits for room in the send channel before trying again later
+	spoolQueueTimeout = 10 * time.Second
+)
+
+// forwardSpool replays spooled messages while the server is reachable and keeps the dropped count
+// reported to the server up to date
+func (c *Client) forwardSpool() {
+	ticker := time.NewTicker(spoolReplayInterval)
+	defer ticker.Stop()
+
+	var reported int64
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if dropped := c.spool.Dropped(); dropped != reported {
+				fmt.Printf("Warning: %d results dropped from the spool\n", dropped-reported)
+				reported = dropped
+			}
+			c.connection.SetDroppedResults(reported)
+
+			if c.spool.Pending() > 0 && c.connection.ReadyForResults() {
+				c.replaySpool()
+			}
+		}
+	}
+}
+
+// replaySpool hands spooled messages to the connection oldest first, stopping when it stops accepting them
+func (c *Client) replaySpool() {
+	replayed := 0
+	defer func() {
+		if replayed > 0 {
+			fmt.Printf("Replayed %d spooled messages\n", replayed)
+		}
+	}()
+
+	for {
+		no, entries, err := c.spool.Next()
+		if err != nil {
+			fmt.Printf("Error reading spool: %v\n", err)
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		sent := 0
+		for _, entry := range entries {
+			if !c.connection.ReadyForResults() || !c.connection.QueueMessage(entry.Type, entry.Data, spoolQueueTimeout) {
+				break
+			}
+			sent++
+		}
+		replayed += sent
+
+		if err := c.spool.Consume(no, entries, sent); err != nil {
+			fmt.Printf("Error updating spool: %v\n", err)
+			return
+		}
+		if sent < len(entries) {
+			return
+		}
+	}
+}
@@ -86,7 +86,7 @@ func (s *SystrayHandler) onReady() {
 
 			case <-mViewDashboard.ClickedCh:
 				// Open browser to dashboard
-				url := fmt.Sprintf("%s/dashboard", s.client.config.ServerAddress)
+				url := fmt.Sprintf("%s/dashboard", s.client.currentConfig().ServerAddress)
 				openBrowser(url)
 
 			case <-mSettings.ClickedCh:
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"networkmonitor/shared"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// makeTCPProbe dials a host:port target and records connection metrics
+func (m *Monitor) makeTCPProbe(target shared.Target) {
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = "tcp://" + target.Address
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeTCP
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	if err := m.dialTCP(ctx, target.Address, targetFamily(target), &result); err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+	}
+
+	m.finishResult(&result)
+}
+
+// dialTCP resolves and connects to address over family, filling in DNS and connect timings
+func (m *Monitor) dialTCP(ctx context.Context, address, family string, result *shared.NetworkRequest) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the host separately so DNS time is not folded into connect time
+	dnsStart := time.Now()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	result.DNSTime = time.Since(dnsStart).Milliseconds()
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+	result.Family = newFamilyInfo(family, addrs, "")
+
+	// Try each resolved address of the family until one accepts the connection
+	err = &net.DNSError{Err: "no " + family + " addresses found", Name: host, IsNotFound: true}
+	for _, addr := range addrs {
+		if family != "" && ipFamily(addr.IP) != family {
+			continue
+		}
+
+		remote := net.JoinHostPort(addr.IP.String(), port)
+		connectStart := time.Now()
+		conn, dialErr := m.dialer.DialContext(ctx, familyNetwork("tcp", family), remote)
+		result.TCPTime = time.Since(connectStart).Milliseconds()
+		if dialErr != nil {
+			err = dialErr
+			continue
+		}
+
+		result.RemoteAddr = conn.RemoteAddr().String()
+		result.Family = newFamilyInfo(family, addrs, result.RemoteAddr)
+		result.TCPInfo = connTCPInfo(conn)
+		conn.Close()
+		return nil
+	}
+
+	return err
+}
+
+// classifyDialError maps a dial error to an error type
+func classifyDialError(err error) string {
+	var dnsErr *net.DNSError
+	var addrErr *net.AddrError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &addrErr):
+		return "address"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	}
+	if errorType := classifyPlatformDialError(err); errorType != "" {
+		return errorType
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	return classifyError(err)
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package client
+
+// classifyPlatformDialError has nothing to add, since the syscall errnos match the socket errors here
+func classifyPlatformDialError(err error) string {
+	return ""
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package client
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// classifyPlatformDialError maps the Winsock errors that do not match the syscall errnos
+func classifyPlatformDialError(err error) string {
+	switch {
+	case errors.Is(err, windows.WSAECONNREFUSED):
+		return "refused"
+	case errors.Is(err, windows.WSAEHOSTUNREACH), errors.Is(err, windows.WSAENETUNREACH):
+		return "unreachable"
+	case errors.Is(err, windows.WSAECONNRESET):
+		return "reset"
+	case errors.Is(err, windows.WSAETIMEDOUT):
+		return "timeout"
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"networkmonitor/shared"
+)
+
+// dialContext dials for the monitor's transports
+func (m *Monitor) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return m.dialer.DialContext(ctx, network, address)
+}
+
+// familyDialer returns a dial function for the monitor's transports that only connects over family
+func (m *Monitor) familyDialer(family string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return m.dialContext(ctx, familyNetwork(network, family), address)
+	}
+}
+
+// connTCPInfo reads kernel TCP statistics for a connection, such as the one handed to GotConn, through
+// the SyscallConn of its underlying *net.TCPConn. It returns nil when they are unavailable.
+func connTCPInfo(conn net.Conn) *shared.TCPInfo {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	info, err := readTCPInfo(tcp)
+	if err != nil {
+		return nil
+	}
+	return info
+}
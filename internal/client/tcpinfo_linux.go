@@ -0,0 +1,44 @@
+//go:build linux
+
+package client
+
+import (
+	"net"
+	"networkmonitor/shared"
+
+	"golang.org/x/sys/unix"
+)
+
+// readTCPInfo reads TCP_INFO for a socket
+func readTCPInfo(conn *net.TCPConn) (*shared.TCPInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var info *unix.TCPInfo
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+
+	return &shared.TCPInfo{
+		RTT:          info.Rtt,
+		RTTVar:       info.Rttvar,
+		MinRTT:       info.Min_rtt,
+		Retransmits:  info.Retransmits,
+		TotalRetrans: info.Total_retrans,
+		Lost:         info.Lost,
+		SndCwnd:      info.Snd_cwnd,
+		SndSsthresh:  info.Snd_ssthresh,
+		SndMSS:       info.Snd_mss,
+		RcvMSS:       info.Rcv_mss,
+		PMTU:         info.Pmtu,
+	}, nil
+}
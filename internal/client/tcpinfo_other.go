@@ -0,0 +1,14 @@
+//go:build !linux
+
+package client
+
+import (
+	"errors"
+	"net"
+	"networkmonitor/shared"
+)
+
+// readTCPInfo reports that kernel TCP statistics are only collected on Linux
+func readTCPInfo(conn *net.TCPConn) (*shared.TCPInfo, error) {
+	return nil, errors.New("TCP_INFO is only supported on Linux")
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"networkmonitor/shared"
+	"os"
+	"strings"
+	"time"
+)
+
+// tlsVersions maps the versions accepted in a target's minVersion to their constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// targetTLSConfig builds the client TLS configuration for target, loading its CA bundle and client certificate
+func targetTLSConfig(target shared.Target) (*tls.Config, error) {
+	config := &tls.Config{}
+	settings := target.TLS
+	if settings == nil {
+		return config, nil
+	}
+
+	if settings.CAFile != "" {
+		pem, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", settings.CAFile)
+		}
+	}
+
+	if settings.CertFile != "" || settings.KeyFile != "" {
+		if settings.CertFile == "" || settings.KeyFile == "" {
+			return nil, errors.New("client certificate needs both certFile and keyFile")
+		}
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if settings.MinVersion != "" {
+		version, found := tlsVersions[strings.TrimPrefix(strings.ToLower(settings.MinVersion), "tls")]
+		if !found {
+			return nil, fmt.Errorf("unsupported minimum TLS version %q", settings.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	config.ServerName = settings.ServerName
+	config.InsecureSkipVerify = settings.Insecure
+	return config, nil
+}
+
+// tlsKey identifies the TLS settings of a target when caching transports
+func tlsKey(target shared.Target) string {
+	if target.TLS == nil {
+		return ""
+	}
+	return fmt.Sprintf("%+v", *target.TLS)
+}
+
+// classifyTLSError maps a TLS failure to an error type, returning "" when err did not come from TLS
+func classifyTLSError(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	var recordHeader tls.RecordHeaderError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "tls_unknown_authority"
+	case errors.As(err, &hostname):
+		return "tls_hostname_mismatch"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "tls_expired"
+	case errors.As(err, &invalid), errors.As(err, &verification):
+		return "tls_invalid_certificate"
+	case errors.As(err, &recordHeader):
+		return "tls_not_tls" // the server did not answer with TLS
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		// Alerts from the server are unexported, so they are told apart by their text
+		alert := opErr.Err.Error()
+		switch {
+		case strings.Contains(alert, "certificate"):
+			return "tls_client_certificate" // missing, untrusted or rejected client certificate
+		case strings.Contains(alert, "protocol version"):
+			return "tls_version"
+		default:
+			return "tls_handshake"
+		}
+	case strings.Contains(err.Error(), "tls: server selected unsupported protocol version"):
+		return "tls_version"
+	case strings.Contains(err.Error(), "server gave HTTP response to HTTPS client"):
+		// The HTTP transport replaces the record header error with this message
+		return "tls_not_tls"
+	}
+	return ""
+}
+
+// newTLSInfo captures the negotiated parameters and peer certificates of a TLS connection
+func newTLSInfo(state tls.ConnectionState) *shared.TLSInfo {
+	info := &shared.TLSInfo{
+		Version:      tls.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		ServerName:   state.ServerName,
+		Certificates: make([]shared.CertificateInfo, 0, len(state.PeerCertificates)),
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, newCertificateInfo(cert))
+	}
+	if len(info.Certificates) > 0 {
+		info.DaysToExpiry = info.Certificates[0].DaysToExpiry
+	}
+
+	return info
+}
+
+// newCertificateInfo summarises a certificate
+func newCertificateInfo(cert *x509.Certificate) shared.CertificateInfo {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses)+len(cert.EmailAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return shared.CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SANs:         sans,
+		SerialNumber: cert.SerialNumber.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		DaysToExpiry: daysUntil(cert.NotAfter),
+	}
+}
+
+// daysUntil returns the number of whole days until t, negative once t has passed
+func daysUntil(t time.Time) int {
+	return int(math.Floor(time.Until(t).Hours() / 24))
+}
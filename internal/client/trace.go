@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"networkmonitor/shared"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultMaxHops is the highest TTL probed by a path trace
+	defaultMaxHops = 30
+
+	// traceBasePort is the first UDP port probed, incremented per hop as traceroute does
+	traceBasePort = 33434
+
+	traceHopTimeout = time.Second
+)
+
+// errTraceUnsupported is returned when path tracing is unavailable on this platform
+var errTraceUnsupported = errors.New("path tracing requires Linux")
+
+// makeTraceProbe discovers the network path to a host one TTL at a time
+func (m *Monitor) makeTraceProbe(target shared.Target) {
+	host, _ := splitPingAddress(target.Address)
+
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = "trace://" + host
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeTrace
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	dnsStart := time.Now()
+	ip, resolved, err := resolveIP(ctx, host, targetFamily(target))
+	result.DNSTime = time.Since(dnsStart).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		m.finishResult(&result)
+		return
+	}
+	result.RemoteAddr = ip.String()
+	result.Family = newFamilyInfo(targetFamily(target), resolved, result.RemoteAddr)
+
+	maxHops := target.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+
+	path := &shared.PathTrace{Destination: ip.String()}
+	result.Path = path
+
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		hop, last, err := traceHop(ip, ttl, traceBasePort+ttl-1, traceHopTimeout)
+		if err != nil {
+			result.Error = err.Error()
+			result.ErrorType = classifyDialError(err)
+			break
+		}
+
+		path.Hops = append(path.Hops, hop)
+		if last {
+			path.Reached = hop.Address == ip.String()
+			if !path.Reached {
+				result.Error = fmt.Sprintf("destination unreachable from %s at hop %d", hop.Address, ttl)
+				result.ErrorType = "unreachable"
+			}
+			break
+		}
+	}
+
+	if result.Error == "" && !path.Reached {
+		result.Error = fmt.Sprintf("destination not reached within %d hops", maxHops)
+		result.ErrorType = "unreachable"
+	}
+
+	m.finishResult(&result)
+}
@@ -0,0 +1,142 @@
+//go:build linux
+
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"networkmonitor/shared"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ICMP time exceeded types reported through the socket error queue
+const (
+	icmpTimeExceeded  = 11
+	icmp6TimeExceeded = 3
+)
+
+// traceHop sends one UDP probe with the given TTL and reads the ICMP reply from the socket
+// error queue, which needs no privileges. It reports whether the reply ends the path.
+func traceHop(ip net.IP, ttl, port int, timeout time.Duration) (shared.PathHop, bool, error) {
+	hop := shared.PathHop{TTL: ttl}
+
+	isV4 := ip.To4() != nil
+	network := "udp4"
+	if !isV4 {
+		network = "udp6"
+	}
+
+	conn, err := net.DialUDP(network, nil, &net.UDPAddr{IP: ip, Port: port})
+	if err != nil {
+		return hop, false, err
+	}
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return hop, false, err
+	}
+
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		if isV4 {
+			if sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL, ttl); sockErr == nil {
+				sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_RECVERR, 1)
+			}
+		} else {
+			if sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl); sockErr == nil {
+				sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_RECVERR, 1)
+			}
+		}
+	})
+	if err != nil {
+		return hop, false, err
+	}
+	if sockErr != nil {
+		return hop, false, sockErr
+	}
+
+	start := time.Now()
+	if _, err := conn.Write([]byte("networkmonitor")); err != nil {
+		return hop, false, err
+	}
+	conn.SetReadDeadline(start.Add(timeout))
+
+	buf := make([]byte, 512)
+	oob := make([]byte, 512)
+	var oobn int
+	var recvErr error
+	err = raw.Read(func(fd uintptr) bool {
+		_, oobn, _, _, recvErr = unix.Recvmsg(int(fd), buf, oob, unix.MSG_ERRQUEUE)
+		return recvErr != unix.EAGAIN
+	})
+	rtt := time.Since(start)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		// No reply from this hop
+		return hop, false, nil
+	}
+	if err != nil {
+		return hop, false, err
+	}
+	if recvErr != nil {
+		return hop, false, recvErr
+	}
+
+	offender, icmpType, ok := parseExtendedErr(oob[:oobn])
+	if !ok {
+		return hop, false, nil
+	}
+
+	hop.Address = offender.String()
+	hop.RTT = durationMillis(rtt)
+
+	// Anything other than time exceeded ends the path, whether or not it came from the destination
+	if isV4 {
+		return hop, icmpType != icmpTimeExceeded, nil
+	}
+	return hop, icmpType != icmp6TimeExceeded, nil
+}
+
+// parseExtendedErr extracts the offending address and ICMP type from an IP_RECVERR control message
+func parseExtendedErr(oob []byte) (net.IP, uint8, bool) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	for _, msg := range msgs {
+		isV4 := msg.Header.Level == unix.SOL_IP && msg.Header.Type == unix.IP_RECVERR
+		isV6 := msg.Header.Level == unix.SOL_IPV6 && msg.Header.Type == unix.IPV6_RECVERR
+		if !isV4 && !isV6 {
+			continue
+		}
+
+		// struct sock_extended_err is 16 bytes and is followed by the offender's sockaddr
+		data := msg.Data
+		if len(data) < 16+4 {
+			continue
+		}
+		origin, icmpType := data[4], data[5]
+		if origin != unix.SO_EE_ORIGIN_ICMP && origin != unix.SO_EE_ORIGIN_ICMP6 {
+			continue
+		}
+
+		sockaddr := data[16:]
+		switch binary.NativeEndian.Uint16(sockaddr) {
+		case unix.AF_INET:
+			if len(sockaddr) >= 8 {
+				return net.IP(append([]byte(nil), sockaddr[4:8]...)), icmpType, true
+			}
+		case unix.AF_INET6:
+			if len(sockaddr) >= 24 {
+				return net.IP(append([]byte(nil), sockaddr[8:24]...)), icmpType, true
+			}
+		}
+	}
+
+	return nil, 0, false
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package client
+
+import (
+	"net"
+	"networkmonitor/shared"
+	"time"
+)
+
+// traceHop reports that path tracing is unavailable without the Linux socket error queue
+func traceHop(ip net.IP, ttl, port int, timeout time.Duration) (shared.PathHop, bool, error) {
+	return shared.PathHop{TTL: ttl}, false, errTraceUnsupported
+}
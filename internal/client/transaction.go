@@ -0,0 +1,208 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"networkmonitor/shared"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// makeTransaction runs the ordered HTTP steps of a transaction target and reports one composite result
+func (m *Monitor) makeTransaction(target shared.Target) {
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = "transaction://" + target.Name
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeTransaction
+
+	if len(target.Steps) == 0 {
+		result.Error = "transaction has no steps"
+		result.ErrorType = "config"
+		m.finishResult(&result)
+		return
+	}
+	result.URL = target.Steps[0].URL
+
+	// Cookies set by one step are sent with the following ones
+	jar, _ := cookiejar.New(nil)
+	vars := make(map[string]string)
+	asserted := false
+
+	for i, step := range target.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		stepTarget := shared.Target{
+			Name:            target.Name + "/" + name,
+			URL:             substituteVars(step.URL, vars),
+			Method:          step.Method,
+			Body:            substituteVars(step.Body, vars),
+			Timeout:         target.Timeout,
+			FollowRedirects: step.FollowRedirects,
+			Assertions:      step.Assertions,
+			Protocol:        target.Protocol,
+			AddressFamily:   target.AddressFamily,
+			Proxy:           target.Proxy,
+			TLS:             target.TLS,
+		}
+		if len(step.Headers) > 0 {
+			stepTarget.Headers = make(map[string]string, len(step.Headers))
+			for key, value := range step.Headers {
+				stepTarget.Headers[key] = substituteVars(value, vars)
+			}
+		}
+
+		stepResult, resp, body := m.doRequest(stepTarget, jar, hasJSONExtract(step.Extract), nil)
+
+		summary := shared.StepResult{
+			Name:        name,
+			URL:         stepResult.URL,
+			Method:      stepResult.Method,
+			StatusCode:  stepResult.StatusCode,
+			DNSTime:     stepResult.DNSTime,
+			TCPTime:     stepResult.TCPTime,
+			TLSTime:     stepResult.TLSTime,
+			RequestTime: stepResult.RequestTime,
+			TotalTime:   stepResult.TotalTime,
+			Error:       stepResult.Error,
+			ErrorType:   stepResult.ErrorType,
+			Assertion:   stepResult.Assertion,
+		}
+
+		result.DNSTime += stepResult.DNSTime
+		result.TCPTime += stepResult.TCPTime
+		result.TLSTime += stepResult.TLSTime
+		result.RequestTime += stepResult.RequestTime
+		result.StatusCode = stepResult.StatusCode
+
+		if stepResult.Error != "" {
+			result.Steps = append(result.Steps, summary)
+			result.Error = fmt.Sprintf("%s: %s", name, stepResult.Error)
+			result.ErrorType = stepResult.ErrorType
+			break
+		}
+
+		if stepResult.Assertion != nil {
+			asserted = true
+			if !stepResult.Assertion.Passed {
+				result.Steps = append(result.Steps, summary)
+				result.Assertion = &shared.AssertionResult{
+					Failure: fmt.Sprintf("%s: %s", name, stepResult.Assertion.Failure),
+				}
+				break
+			}
+		}
+
+		// Make extracted values available to later steps
+		var extractErr error
+		for varName, source := range step.Extract {
+			value, err := extractValue(source, resp, body, jar)
+			if err != nil {
+				extractErr = fmt.Errorf("extract %s: %w", varName, err)
+				break
+			}
+			vars[varName] = value
+			summary.Extracted = append(summary.Extracted, varName)
+		}
+
+		if extractErr != nil {
+			summary.Error = extractErr.Error()
+			summary.ErrorType = "extract"
+			result.Steps = append(result.Steps, summary)
+			result.Error = fmt.Sprintf("%s: %s", name, extractErr)
+			result.ErrorType = "extract"
+			break
+		}
+
+		result.Steps = append(result.Steps, summary)
+	}
+
+	if asserted && result.Assertion == nil && result.Error == "" {
+		result.Assertion = &shared.AssertionResult{Passed: true}
+	}
+
+	m.finishResult(&result)
+}
+
+// substituteVars replaces {{name}} references with extracted values
+func substituteVars(text string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(text, "{{") {
+		return text
+	}
+
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, "{{"+name+"}}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// hasJSONExtract reports whether any extraction reads from the response body
+func hasJSONExtract(extract map[string]string) bool {
+	for _, source := range extract {
+		if strings.HasPrefix(source, "json:") {
+			return true
+		}
+	}
+	return false
+}
+
+// extractValue reads a value from a step response using a "header:", "cookie:" or "json:" source
+func extractValue(source string, resp *http.Response, body []byte, jar http.CookieJar) (string, error) {
+	kind, key, found := strings.Cut(source, ":")
+	if !found || key == "" {
+		return "", fmt.Errorf("invalid source %q", source)
+	}
+
+	switch kind {
+	case "header":
+		values, found := resp.Header[http.CanonicalHeaderKey(key)]
+		if !found || len(values) == 0 {
+			return "", fmt.Errorf("header %s missing", key)
+		}
+		return values[0], nil
+
+	case "cookie":
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == key {
+				return cookie.Value, nil
+			}
+		}
+		// Cookies set earlier in a redirect chain only reach the jar
+		for _, cookie := range jar.Cookies(resp.Request.URL) {
+			if cookie.Name == key {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie %s missing", key)
+
+	case "json":
+		if body == nil {
+			return "", errors.New("response has no body")
+		}
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return "", fmt.Errorf("body is not valid JSON: %w", err)
+		}
+		value, found := lookupJSONPath(doc, key)
+		if !found {
+			return "", fmt.Errorf("JSON path %s not found", key)
+		}
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+		return fmt.Sprint(value), nil
+
+	default:
+		return "", fmt.Errorf("unknown source type %q", kind)
+	}
+}
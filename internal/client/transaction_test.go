@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"networkmonitor/shared"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTransactionServer starts a TLS server whose login step issues a token that the next step requires,
+// and writes its certificate to a CA file
+func newTransactionServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"secret"}`))
+	})
+	mux.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certificate, 0644); err != nil {
+		t.Fatalf("write ca file: %v", err)
+	}
+	return server, caFile
+}
+
+// transactionTarget logs in to server and fetches the account with the extracted token
+func transactionTarget(server *httptest.Server, tlsConfig *shared.TLSConfig) shared.Target {
+	return shared.Target{
+		Name:    "login",
+		Type:    shared.ProbeTransaction,
+		Timeout: 5000,
+		TLS:     tlsConfig,
+		Steps: []shared.TransactionStep{
+			{Name: "login", URL: server.URL + "/login", Extract: map[string]string{"token": "json:token"}},
+			{
+				Name:       "account",
+				URL:        server.URL + "/account",
+				Headers:    map[string]string{"Authorization": "Bearer {{token}}"},
+				Assertions: &shared.Assertions{StatusCodes: []string{"200"}},
+			},
+		},
+	}
+}
+
+// runTransaction runs target and returns its composite result
+func runTransaction(t *testing.T, target shared.Target) shared.NetworkRequest {
+	t.Helper()
+
+	m := NewMonitor()
+	go m.makeTransaction(target)
+	return <-m.resultChan
+}
+
+func TestTransactionUsesTargetCAFile(t *testing.T) {
+	server, caFile := newTransactionServer(t)
+
+	result := runTransaction(t, transactionTarget(server, &shared.TLSConfig{CAFile: caFile}))
+
+	if result.Error != "" {
+		t.Fatalf("unexpected error %q (%s)", result.Error, result.ErrorType)
+	}
+	if len(result.Steps) != 2 {
+		t.Fatalf("ran %d steps, want 2", len(result.Steps))
+	}
+	if result.Assertion == nil || !result.Assertion.Passed {
+		t.Errorf("assertion = %+v, want passed", result.Assertion)
+	}
+}
+
+func TestTransactionWithoutCAFileIsUntrusted(t *testing.T) {
+	server, _ := newTransactionServer(t)
+
+	result := runTransaction(t, transactionTarget(server, nil))
+
+	if result.ErrorType != "tls_unknown_authority" {
+		t.Errorf("error %q (%s), want tls_unknown_authority", result.Error, result.ErrorType)
+	}
+	if len(result.Steps) != 1 {
+		t.Errorf("ran %d steps, want to stop after the first", len(result.Steps))
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"networkmonitor/shared"
+	"time"
+)
+
+// defaultMaxBodyBytes caps how much of a response body is downloaded when measuring throughput
+const defaultMaxBodyBytes = 10 << 20
+
+// bodyLimit returns the download cap configured for a target
+func bodyLimit(target shared.Target) int64 {
+	if target.MaxBodyBytes > 0 {
+		return target.MaxBodyBytes
+	}
+	return defaultMaxBodyBytes
+}
+
+// measureBody downloads up to limit bytes of a response body and records transfer statistics.
+// The body is only retained when keep is set.
+func measureBody(resp *http.Response, limit int64, keep bool, start, firstByte time.Time) ([]byte, *shared.TransferStats, error) {
+	var buf bytes.Buffer
+	var dst io.Writer = io.Discard
+	if keep {
+		dst = &buf
+	}
+
+	n, err := io.Copy(dst, io.LimitReader(resp.Body, limit))
+	end := time.Now()
+
+	stats := &shared.TransferStats{
+		BytesTransferred: n,
+		ContentLength:    resp.ContentLength,
+		ContentEncoding:  resp.Header.Get("Content-Encoding"),
+		Decompressed:     resp.Uncompressed,
+		TimeToLastByte:   end.Sub(start).Milliseconds(),
+	}
+
+	// The transport strips Content-Encoding when it transparently decompresses gzip
+	if resp.Uncompressed {
+		stats.ContentEncoding = "gzip"
+	}
+
+	// Anything left after the cap means the download was cut short
+	if err == nil && n == limit {
+		var probe [1]byte
+		if extra, _ := resp.Body.Read(probe[:]); extra > 0 {
+			stats.Truncated = true
+		}
+	}
+
+	if firstByte.IsZero() {
+		firstByte = start
+	}
+	if elapsed := end.Sub(firstByte); elapsed > 0 {
+		stats.Throughput = float64(n) / elapsed.Seconds()
+	}
+
+	return buf.Bytes(), stats, err
+}
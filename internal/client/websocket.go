@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"networkmonitor/shared"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// maxWebSocketReply caps how much of a reply is stored with the result
+const maxWebSocketReply = 1024
+
+// makeWebSocketProbe opens a WebSocket connection and optionally exchanges a message with the server
+func (m *Monitor) makeWebSocketProbe(target shared.Target) {
+	var result shared.NetworkRequest
+	result.ID = uuid.New().String()
+	result.URL = target.URL
+	result.Method = http.MethodGet
+	result.StartTime = time.Now()
+	result.TargetName = target.Name
+	result.ProbeType = shared.ProbeWebSocket
+	result.WebSocket = &shared.WebSocketResult{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout(target))
+	defer cancel()
+
+	var dnsStart, connectStart, tlsStart, upgradeStart time.Time
+	var resolved []net.IPAddr
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			result.DNSTime = time.Since(dnsStart).Milliseconds()
+			resolved = info.Addrs
+		},
+		ConnectStart: func(network, addr string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			result.TCPTime = time.Since(connectStart).Milliseconds()
+			if err == nil {
+				result.RemoteAddr = addr
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			upgradeStart = time.Now()
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			result.TLSTime = time.Since(tlsStart).Milliseconds()
+			if err == nil {
+				result.TLS = newTLSInfo(state)
+			}
+			// The upgrade request is only sent once the TLS handshake is done
+			upgradeStart = time.Now()
+		},
+	}
+
+	tlsConfig, err := targetTLSConfig(target)
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = "config"
+		m.finishResult(&result)
+		return
+	}
+
+	dialer := websocket.Dialer{
+		NetDialContext:  m.familyDialer(targetFamily(target)),
+		TLSClientConfig: tlsConfig,
+	}
+
+	header := make(http.Header)
+	for name, value := range target.Headers {
+		header.Set(name, value)
+	}
+
+	conn, resp, err := dialer.DialContext(httptrace.WithClientTrace(ctx, trace), target.URL, header)
+	if !upgradeStart.IsZero() {
+		result.WebSocket.HandshakeTime = time.Since(upgradeStart).Milliseconds()
+	}
+	result.Family = newFamilyInfo(targetFamily(target), resolved, result.RemoteAddr)
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorType = classifyDialError(err)
+		if errorType := classifyTLSError(err); errorType != "" {
+			result.ErrorType = errorType
+		}
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			result.ErrorType = "ws_handshake"
+		}
+		m.finishResult(&result)
+		return
+	}
+	defer conn.Close()
+
+	result.TCPInfo = connTCPInfo(conn.UnderlyingConn())
+	result.WebSocket.Subprotocol = conn.Subprotocol()
+
+	if target.Message != "" {
+		deadline, _ := ctx.Deadline()
+		if target.ReplyTimeout > 0 {
+			deadline = time.Now().Add(time.Duration(target.ReplyTimeout) * time.Millisecond)
+		}
+		if err := exchangeWebSocketMessage(conn, target, deadline, &result); err != nil {
+			result.Error = err.Error()
+		}
+	}
+
+	// Close politely so the server does not log an abnormal closure
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+
+	m.finishResult(&result)
+}
+
+// exchangeWebSocketMessage sends the target's message and reads replies until one contains the expected text
+func exchangeWebSocketMessage(conn *websocket.Conn, target shared.Target, deadline time.Time, result *shared.NetworkRequest) error {
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+
+	requestStart := time.Now()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(target.Message)); err != nil {
+		result.ErrorType = "ws_write"
+		return err
+	}
+
+	for {
+		_, reply, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			var closeErr *websocket.CloseError
+			switch {
+			case errors.As(err, &netErr) && netErr.Timeout():
+				// Replies that arrived without the expected text are a mismatch rather than silence
+				if result.WebSocket.Reply != "" {
+					result.ErrorType = "ws_unexpected_reply"
+					return errors.New("no reply contained the expected text")
+				}
+				result.ErrorType = "ws_reply_timeout"
+				return errors.New("no reply before the deadline")
+			case errors.As(err, &closeErr), errors.Is(err, io.ErrUnexpectedEOF):
+				result.ErrorType = "ws_closed"
+			default:
+				result.ErrorType = "ws_read"
+			}
+			return err
+		}
+
+		result.RequestTime = time.Since(requestStart).Milliseconds()
+		result.WebSocket.ReplyTime = result.RequestTime
+		result.WebSocket.Reply = string(reply)
+		if len(reply) > maxWebSocketReply {
+			result.WebSocket.Reply = string(reply[:maxWebSocketReply])
+		}
+
+		if strings.Contains(string(reply), target.ExpectedReply) {
+			result.WebSocket.Matched = true
+			return nil
+		}
+	}
+}
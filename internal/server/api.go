@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"networkmonitor/shared"
+	"sort"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -64,6 +65,14 @@ func (a *API) setupRoutes() {
 	a.router.GET("/api/clients", a.getClients)
 	a.router.GET("/api/clients/:id", a.getClient)
 	a.router.GET("/api/clients/:id/requests", a.getClientRequests)
+	a.router.GET("/api/clients/:id/protocols", a.getClientProtocols)
+	a.router.GET("/api/clients/:id/dualstack", a.getClientDualStack)
+	a.router.GET("/api/clients/:id/network", a.getClientNetwork)
+	a.router.GET("/api/clients/:id/metrics", a.getClientMetrics)
+	a.router.GET("/api/clients/:id/network-state", a.getClientNetworkState)
+
+	// Certificate API
+	a.router.GET("/api/certificates/expiring", a.getExpiringCertificates)
 
 	// Config API
 	a.router.GET("/api/config", a.getConfig)
@@ -121,8 +130,16 @@ func (a *API) getClientRequests(c *gin.Context) {
 		}
 	}
 	
-	// Get requests from storage
-	requests, err := a.clientManager.storage.GetNetworkRequests(id, limit)
+	// Get requests from storage, optionally filtered by probe type. suppressed=false leaves out
+	// successes taken behind a captive portal or interception.
+	probeType := c.Query("type")
+	hideSuppressed := c.Query("suppressed") == "false"
+	requests, err := a.clientManager.storage.GetNetworkRequestsMatching(id, limit, func(request shared.NetworkRequest) bool {
+		if probeType != "" && !hasProbeType(request, probeType) {
+			return false
+		}
+		return !hideSuppressed || !request.Suppressed
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get requests"})
 		return
@@ -131,6 +148,272 @@ func (a *API) getClientRequests(c *gin.Context) {
 	c.JSON(http.StatusOK, requests)
 }
 
+// getClientProtocols compares HTTP timings per URL across the protocols a client used
+func (a *API) getClientProtocols(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 1000
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if _, err := fmt.Sscanf(limitParam, "%d", &limit); err != nil {
+			limit = 1000
+		}
+	}
+
+	requests, err := a.clientManager.storage.GetNetworkRequestsByType(id, shared.ProbeHTTP, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get requests"})
+		return
+	}
+
+	type protocolKey struct{ url, protocol string }
+	statsByKey := make(map[protocolKey]*shared.ProtocolStats)
+	for _, request := range requests {
+		if request.Protocol == "" || request.Suppressed {
+			continue
+		}
+
+		key := protocolKey{request.URL, request.Protocol}
+		stats, found := statsByKey[key]
+		if !found {
+			stats = &shared.ProtocolStats{URL: request.URL, Protocol: request.Protocol}
+			statsByKey[key] = stats
+		}
+
+		stats.Requests++
+		if request.Error != "" {
+			stats.Failures++
+			continue
+		}
+		stats.AvgDNSTime += float64(request.DNSTime)
+		stats.AvgTCPTime += float64(request.TCPTime)
+		stats.AvgTLSTime += float64(request.TLSTime)
+		stats.AvgRequestTime += float64(request.RequestTime)
+		stats.AvgTotalTime += float64(request.TotalTime)
+	}
+
+	protocols := []shared.ProtocolStats{}
+	for _, stats := range statsByKey {
+		if succeeded := float64(stats.Requests - stats.Failures); succeeded > 0 {
+			stats.AvgDNSTime /= succeeded
+			stats.AvgTCPTime /= succeeded
+			stats.AvgTLSTime /= succeeded
+			stats.AvgRequestTime /= succeeded
+			stats.AvgTotalTime /= succeeded
+		}
+		protocols = append(protocols, *stats)
+	}
+
+	sort.Slice(protocols, func(i, j int) bool {
+		if protocols[i].URL != protocols[j].URL {
+			return protocols[i].URL < protocols[j].URL
+		}
+		return protocols[i].Protocol < protocols[j].Protocol
+	})
+
+	c.JSON(http.StatusOK, protocols)
+}
+
+// getClientDualStack reports per target how a client reaches it over IPv4 and IPv6
+func (a *API) getClientDualStack(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 1000
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if _, err := fmt.Sscanf(limitParam, "%d", &limit); err != nil {
+			limit = 1000
+		}
+	}
+
+	requests, err := a.clientManager.storage.GetNetworkRequests(id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get requests"})
+		return
+	}
+
+	healthByTarget := make(map[string]*shared.DualStackHealth)
+	for _, request := range requests {
+		if request.Family == nil || request.Suppressed {
+			continue
+		}
+
+		health, found := healthByTarget[request.TargetName]
+		if !found {
+			health = &shared.DualStackHealth{TargetName: request.TargetName}
+			healthByTarget[request.TargetName] = health
+		}
+
+		// Forced probes measure each family, unforced ones show which family the client settles on
+		var family *shared.FamilyHealth
+		switch request.Family.Requested {
+		case shared.FamilyIPv4:
+			family = &health.IPv4
+		case shared.FamilyIPv6:
+			family = &health.IPv6
+		default:
+			if request.Family.Fallback {
+				health.Fallbacks++
+			} else if request.Family.Used == shared.FamilyIPv6 && request.Family.ResolvedIPv4 {
+				health.Preferred++
+			}
+			continue
+		}
+
+		family.Requests++
+		if request.Error != "" {
+			family.Failures++
+			continue
+		}
+		family.AvgTotalTime += float64(request.TotalTime)
+	}
+
+	report := []shared.DualStackHealth{}
+	for _, health := range healthByTarget {
+		for _, family := range []*shared.FamilyHealth{&health.IPv4, &health.IPv6} {
+			if succeeded := float64(family.Requests - family.Failures); succeeded > 0 {
+				family.AvgTotalTime /= succeeded
+			}
+		}
+		report = append(report, *health)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].TargetName < report[j].TargetName
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+// getClientNetwork returns a client's network environment history, newest first
+func (a *API) getClientNetwork(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 100
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if _, err := fmt.Sscanf(limitParam, "%d", &limit); err != nil {
+			limit = 100
+		}
+	}
+
+	// changes=true keeps only the snapshots where the interfaces, addresses, gateway or DNS servers changed
+	changedOnly := c.Query("changes") == "true"
+
+	environments, err := a.clientManager.storage.GetNetworkEnvironments(id, limit, changedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get network environment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, environments)
+}
+
+// getClientMetrics returns a client's host resource usage between from and to, which default to the last 24 hours
+func (a *API) getClientMetrics(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 1000
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if _, err := fmt.Sscanf(limitParam, "%d", &limit); err != nil {
+			limit = 1000
+		}
+	}
+
+	to := time.Now()
+	if toParam := c.Query("to"); toParam != "" {
+		parsed, err := time.Parse(time.RFC3339, toParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time, expected RFC 3339"})
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromParam := c.Query("from"); fromParam != "" {
+		parsed, err := time.Parse(time.RFC3339, fromParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time, expected RFC 3339"})
+			return
+		}
+		from = parsed
+	}
+
+	metrics, err := a.clientManager.storage.GetHostMetrics(id, from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get host metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// getClientNetworkState returns a client's captive portal check history, newest first
+func (a *API) getClientNetworkState(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 100
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if _, err := fmt.Sscanf(limitParam, "%d", &limit); err != nil {
+			limit = 100
+		}
+	}
+
+	states, err := a.clientManager.storage.GetNetworkStates(id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get network state"})
+		return
+	}
+
+	c.JSON(http.StatusOK, states)
+}
+
+// certificateResultDays bounds how far back the expiring certificates report looks for TLS results
+const certificateResultDays = 7
+
+// getExpiringCertificates returns certificates seen by any client that expire within the given number of days
+func (a *API) getExpiringCertificates(c *gin.Context) {
+	days := 30
+	if daysParam := c.Query("days"); daysParam != "" {
+		if _, err := fmt.Sscanf(daysParam, "%d", &days); err != nil {
+			days = 30
+		}
+	}
+
+	// Only the latest TLS result per client and target reflects the certificate currently served;
+	// targets without one in the last week are no longer probed
+	latest, err := a.clientManager.storage.GetLatestNetworkRequests(certificateResultDays, func(request shared.NetworkRequest) bool {
+		return request.TLS != nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get requests"})
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, days)
+	expiring := []shared.ExpiringCertificate{}
+	for clientID, requests := range latest {
+		for _, request := range requests {
+			for _, cert := range request.TLS.Certificates {
+				if cert.NotAfter.After(cutoff) {
+					continue
+				}
+				expiring = append(expiring, shared.ExpiringCertificate{
+					ClientID:    clientID,
+					TargetName:  request.TargetName,
+					URL:         request.URL,
+					ObservedAt:  request.StartTime,
+					Certificate: cert,
+				})
+			}
+		}
+	}
+
+	// Soonest expiry first
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].Certificate.NotAfter.Before(expiring[j].Certificate.NotAfter)
+	})
+
+	c.JSON(http.StatusOK, expiring)
+}
+
 // getConfig returns the server configuration
 func (a *API) getConfig(c *gin.Context) {
 	config, err := a.clientManager.storage.GetServerConfig()
@@ -153,6 +436,7 @@ func (a *API) updateConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
 		return
 	}
+	a.clientManager.UpdateConfig(config)
 	
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
\ No newline at end of file
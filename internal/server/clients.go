@@ -19,6 +19,21 @@ type ClientConnection struct {
 	sendChan     chan shared.ServerMessage
 	stopChan     chan struct{}
 	wg           sync.WaitGroup
+	skewSamples  []int64 // recent client minus server timestamps, in milliseconds
+	firstHop     map[string]firstHopState // latest result of each built-in gateway and resolver target
+}
+
+// maxSkewSamples is the number of recent messages the clock skew is estimated from
+const maxSkewSamples = 20
+
+// firstHopMaxAge is how far apart a gateway or resolver result and a failure may be for one to explain the other
+const firstHopMaxAge = 5 * time.Minute
+
+// firstHopState is the outcome of the latest probe of a gateway or resolver
+type firstHopState struct {
+	scope  string
+	failed bool
+	at     time.Time // when the probe finished, so spooled and retransmitted results are judged by their own time
 }
 
 // NewClientConnection creates a new client connection
@@ -28,6 +43,7 @@ func NewClientConnection(ws *websocket.Conn, clientMgr *ClientManager) *ClientCo
 		clientMgr: clientMgr,
 		sendChan:  make(chan shared.ServerMessage, 100),
 		stopChan:  make(chan struct{}),
+		firstHop:  make(map[string]firstHopState),
 	}
 }
 
@@ -118,12 +134,35 @@ func (c *ClientConnection) receiveLoop() {
 				c.clientID = clientMsg.ClientID
 			}
 
+			c.recordClockSkew(clientMsg.Timestamp, time.Now())
+
 			// Handle message based on type
 			c.handleClientMessage(clientMsg)
 		}
 	}
 }
 
+// recordClockSkew updates the client's clock skew estimate from the send time of a message.
+// Transit delay only makes a message look older, so the largest recent difference is the closest estimate.
+func (c *ClientConnection) recordClockSkew(sent, received time.Time) {
+	if sent.IsZero() {
+		return
+	}
+
+	c.skewSamples = append(c.skewSamples, sent.Sub(received).Milliseconds())
+	if len(c.skewSamples) > maxSkewSamples {
+		c.skewSamples = c.skewSamples[1:]
+	}
+
+	skew := c.skewSamples[0]
+	for _, sample := range c.skewSamples[1:] {
+		if sample > skew {
+			skew = sample
+		}
+	}
+	c.clientInfo.ClockSkew = skew
+}
+
 // handleClientMessage processes messages from the client
 func (c *ClientConnection) handleClientMessage(msg shared.ClientMessage) {
 	switch msg.Type {
@@ -177,9 +216,113 @@ func (c *ClientConnection) handleClientMessage(msg shared.ClientMessage) {
 			var request shared.NetworkRequest
 			requestBytes, _ := json.Marshal(requestData)
 			json.Unmarshal(requestBytes, &request)
+
+			// Results are sent again when their acknowledgement was lost, so only acknowledge those already stored
+			if c.clientMgr.storage.HasNetworkRequest(c.clientID, request) {
+				c.acknowledge(msg.Sequence)
+				return
+			}
+
+			// Move the client's timestamps onto the server clock when configured
+			if skew := c.skewCorrection(); skew != 0 {
+				correction := time.Duration(skew) * time.Millisecond
+				request.StartTime = request.StartTime.Add(-correction)
+				request.EndTime = request.EndTime.Add(-correction)
+				request.SkewCorrection = skew
+			}
+
+			// Attribute failures to the local network, the resolver or what lies beyond
+			if request.Scope != "" {
+				c.firstHop[request.TargetName] = firstHopState{
+					scope:  request.Scope,
+					failed: request.Error != "",
+					at:     request.EndTime,
+				}
+			}
+			if request.Error != "" {
+				request.FailureScope = c.classifyFailure(request)
+			}
+
+			// A success behind a captive portal or interception may be the portal answering
+			if succeeded(request) && restrictedNetwork(request.NetworkState) {
+				request.Suppressed = true
+			}
+
+			// Flag traced paths that differ from the previous complete run; a failed or partial
+			// trace is kept with its result but neither replaces the stored path nor flags a change
+			if request.Path != nil && request.Path.Reached {
+				if changed, err := c.clientMgr.storage.RecordPath(c.clientID, request.TargetName, *request.Path); err == nil {
+					request.Path.Changed = changed
+				} else {
+					fmt.Printf("Error recording path: %v\n", err)
+				}
+			}
 			
-			// Store request
-			c.clientMgr.storage.SaveNetworkRequest(c.clientID, request)
+			// Store request, acknowledging it only once it is on disk
+			if err := c.clientMgr.storage.SaveNetworkRequest(c.clientID, request); err != nil {
+				fmt.Printf("Error saving network request: %v\n", err)
+				return
+			}
+			c.acknowledge(msg.Sequence)
+		}
+
+	case shared.TypeNetworkEnvironment:
+		// Handle network environment snapshot
+		if envData, ok := msg.Data.(map[string]interface{}); ok {
+			// Convert to NetworkEnvironment
+			var env shared.NetworkEnvironment
+			envBytes, _ := json.Marshal(envData)
+			json.Unmarshal(envBytes, &env)
+
+			if skew := c.skewCorrection(); skew != 0 {
+				env.CollectedAt = env.CollectedAt.Add(-time.Duration(skew) * time.Millisecond)
+			}
+
+			// Store snapshot, flagging what changed since the previous one
+			if err := c.clientMgr.storage.SaveNetworkEnvironment(c.clientID, env); err != nil {
+				fmt.Printf("Error saving network environment: %v\n", err)
+			}
+		}
+
+	case shared.TypeNetworkState:
+		// Handle captive portal check
+		if stateData, ok := msg.Data.(map[string]interface{}); ok {
+			// Convert to NetworkState
+			var state shared.NetworkState
+			stateBytes, _ := json.Marshal(stateData)
+			json.Unmarshal(stateBytes, &state)
+
+			if skew := c.skewCorrection(); skew != 0 {
+				state.CheckedAt = state.CheckedAt.Add(-time.Duration(skew) * time.Millisecond)
+			}
+
+			if state.State != c.clientInfo.NetworkState {
+				fmt.Printf("Client %s network state: %s %s\n", c.clientID, state.State, state.Detail)
+				c.clientInfo.NetworkState = state.State
+				c.clientMgr.storage.SaveClientInfo(c.clientInfo)
+			}
+
+			if err := c.clientMgr.storage.SaveNetworkState(c.clientID, state); err != nil {
+				fmt.Printf("Error saving network state: %v\n", err)
+			}
+		}
+
+	case shared.TypeHostMetrics:
+		// Handle host resource usage sample
+		if metricsData, ok := msg.Data.(map[string]interface{}); ok {
+			// Convert to HostMetrics
+			var metrics shared.HostMetrics
+			metricsBytes, _ := json.Marshal(metricsData)
+			json.Unmarshal(metricsBytes, &metrics)
+
+			// Keep samples on the same clock as the requests they are overlaid on
+			if skew := c.skewCorrection(); skew != 0 {
+				metrics.CollectedAt = metrics.CollectedAt.Add(-time.Duration(skew) * time.Millisecond)
+			}
+
+			if err := c.clientMgr.storage.SaveHostMetrics(c.clientID, metrics); err != nil {
+				fmt.Printf("Error saving host metrics: %v\n", err)
+			}
 		}
 
 	default:
@@ -187,19 +330,114 @@ func (c *ClientConnection) handleClientMessage(msg shared.ClientMessage) {
 	}
 }
 
+// classifyFailure judges from the latest first-hop results whether a failed request was caused by the
+// client's local network, its DNS resolvers or something upstream. It returns "" when the client
+// has not reported any recent first-hop results.
+func (c *ClientConnection) classifyFailure(request shared.NetworkRequest) string {
+	gatewayKnown, gatewayFailed := false, false
+	resolversKnown, resolverWorking := false, false
+	for name, state := range c.firstHop {
+		if request.EndTime.Sub(state.at) > firstHopMaxAge {
+			delete(c.firstHop, name)
+			continue
+		}
+		if state.at.Sub(request.EndTime) > firstHopMaxAge {
+			continue
+		}
+		switch state.scope {
+		case shared.ScopeGateway:
+			gatewayKnown = true
+			gatewayFailed = gatewayFailed || state.failed
+		case shared.ScopeResolver:
+			resolversKnown = true
+			resolverWorking = resolverWorking || !state.failed
+		}
+	}
+
+	switch {
+	case request.Scope == shared.ScopeGateway || gatewayFailed:
+		return shared.FailureLocalNetwork
+	case request.Scope == shared.ScopeResolver:
+		return shared.FailureResolver
+	case resolversKnown && !resolverWorking:
+		// The system falls back between resolvers, so only all of them failing breaks name resolution
+		return shared.FailureResolver
+	case gatewayKnown || resolversKnown:
+		return shared.FailureUpstream
+	default:
+		return ""
+	}
+}
+
+// acknowledge tells the client a message was persisted. Messages from clients that do not number them are not acknowledged.
+func (c *ClientConnection) acknowledge(sequence uint64) {
+	if sequence == 0 {
+		return
+	}
+	c.SendMessage(shared.TypeAck, shared.MessageAck{Sequence: sequence})
+}
+
+// succeeded reports whether a result is a success: no error, no HTTP error status and no failed assertion
+func succeeded(request shared.NetworkRequest) bool {
+	return request.Error == "" && request.StatusCode < 400 && (request.Assertion == nil || request.Assertion.Passed)
+}
+
+// restrictedNetwork reports whether a network state means responses may not come from their targets
+func restrictedNetwork(state string) bool {
+	switch state {
+	case shared.NetworkStateCaptivePortal, shared.NetworkStateTampered, shared.NetworkStateIntercepted:
+		return true
+	}
+	return false
+}
+
+// skewCorrection returns the client's clock skew in milliseconds when the server is configured
+// to correct client timestamps, and 0 otherwise
+func (c *ClientConnection) skewCorrection() int64 {
+	if c.clientInfo.ClockSkew == 0 {
+		return 0
+	}
+	if !c.clientMgr.correctsClockSkew() {
+		return 0
+	}
+	return c.clientInfo.ClockSkew
+}
+
 // ClientManager manages client connections
 type ClientManager struct {
 	clients     map[string]*ClientConnection
 	storage     *Storage
 	mutex       sync.RWMutex
+
+	// correctClockSkew caches the server setting, which is needed for every client message
+	correctClockSkew bool
+	configMutex      sync.RWMutex
 }
 
 // NewClientManager creates a new client manager
 func NewClientManager(storage *Storage) *ClientManager {
-	return &ClientManager{
+	manager := &ClientManager{
 		clients: make(map[string]*ClientConnection),
 		storage: storage,
 	}
+	if config, err := storage.GetServerConfig(); err == nil {
+		manager.UpdateConfig(config)
+	}
+	return manager
+}
+
+// UpdateConfig applies the server settings the client manager caches
+func (m *ClientManager) UpdateConfig(config shared.ServerConfig) {
+	m.configMutex.Lock()
+	defer m.configMutex.Unlock()
+	m.correctClockSkew = config.CorrectClockSkew
+}
+
+// correctsClockSkew returns whether client timestamps are corrected for clock skew
+func (m *ClientManager) correctsClockSkew() bool {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
+	return m.correctClockSkew
 }
 
 // AddClient adds a client connection
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"networkmonitor/shared"
+	"testing"
+	"time"
+)
+
+// newTestConnection returns a connection for a client backed by storage in a temporary directory
+func newTestConnection(t *testing.T) *ClientConnection {
+	t.Helper()
+
+	storage, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	c := NewClientConnection(nil, NewClientManager(storage))
+	c.clientID = "client"
+	return c
+}
+
+// deliver hands request to the connection as the client would send it
+func deliver(t *testing.T, c *ClientConnection, request shared.NetworkRequest) {
+	t.Helper()
+
+	data, _ := json.Marshal(request)
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	c.handleClientMessage(shared.ClientMessage{Type: shared.TypeNetworkRequest, Data: fields})
+}
+
+// storedFailureScopes returns the failure scope stored for each request ID
+func storedFailureScopes(t *testing.T, c *ClientConnection) map[string]string {
+	t.Helper()
+
+	requests, err := c.clientMgr.storage.GetNetworkRequests(c.clientID, 100)
+	if err != nil {
+		t.Fatalf("requests: %v", err)
+	}
+	scopes := make(map[string]string)
+	for _, request := range requests {
+		scopes[request.ID] = request.FailureScope
+	}
+	return scopes
+}
+
+// result builds a request from the client that ended at the given time
+func result(id, target, scope, err string, at time.Time) shared.NetworkRequest {
+	return shared.NetworkRequest{
+		ID:         id,
+		TargetName: target,
+		Scope:      scope,
+		Error:      err,
+		StartTime:  at.Add(-time.Second),
+		EndTime:    at,
+	}
+}
+
+func TestReplayedFailuresUseFirstHopResultsFromTheirPeriod(t *testing.T) {
+	c := newTestConnection(t)
+	outage := time.Now().Add(-2 * time.Hour)
+
+	// Results spooled during an outage two hours ago are replayed after reconnecting
+	deliver(t, c, result("gateway-down", "gateway", shared.ScopeGateway, "timeout", outage))
+	deliver(t, c, result("replayed", "web", "", "timeout", outage.Add(30*time.Second)))
+
+	// Live results follow once the network is back
+	now := time.Now()
+	deliver(t, c, result("gateway-up", "gateway", shared.ScopeGateway, "", now))
+	deliver(t, c, result("live", "web", "", "timeout", now))
+
+	scopes := storedFailureScopes(t, c)
+	if scopes["replayed"] != shared.FailureLocalNetwork {
+		t.Errorf("replayed failure scope = %q, want %q", scopes["replayed"], shared.FailureLocalNetwork)
+	}
+	if scopes["live"] != shared.FailureUpstream {
+		t.Errorf("live failure scope = %q, want %q", scopes["live"], shared.FailureUpstream)
+	}
+}
+
+func TestFailuresIgnoreFirstHopResultsFromAnotherPeriod(t *testing.T) {
+	c := newTestConnection(t)
+	now := time.Now()
+
+	// A failure replayed after newer first-hop results is not judged by them
+	deliver(t, c, result("gateway-down", "gateway", shared.ScopeGateway, "timeout", now))
+	deliver(t, c, result("replayed", "web", "", "timeout", now.Add(-time.Hour)))
+
+	// Nor is a failure long after the last first-hop result
+	deliver(t, c, result("later", "web", "", "timeout", now.Add(time.Hour)))
+
+	scopes := storedFailureScopes(t, c)
+	if scopes["replayed"] != "" {
+		t.Errorf("replayed failure scope = %q, want none", scopes["replayed"])
+	}
+	if scopes["later"] != "" {
+		t.Errorf("later failure scope = %q, want none", scopes["later"])
+	}
+}
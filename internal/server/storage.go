@@ -3,9 +3,12 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"networkmonitor/shared"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,6 +18,10 @@ type Storage struct {
 	dataDir      string
 	clientsDir   string
 	requestsDir  string
+	pathsDir     string
+	networkDir   string
+	metricsDir   string
+	statesDir    string
 	configFile   string
 	mutex        sync.RWMutex
 }
@@ -24,9 +31,13 @@ func NewStorage(dataDir string) (*Storage, error) {
 	// Create directory structure
 	clientsDir := filepath.Join(dataDir, "clients")
 	requestsDir := filepath.Join(dataDir, "requests")
+	pathsDir := filepath.Join(dataDir, "paths")
+	networkDir := filepath.Join(dataDir, "network")
+	metricsDir := filepath.Join(dataDir, "metrics")
+	statesDir := filepath.Join(dataDir, "states")
 	configFile := filepath.Join(dataDir, "config.json")
 
-	dirs := []string{dataDir, clientsDir, requestsDir}
+	dirs := []string{dataDir, clientsDir, requestsDir, pathsDir, networkDir, metricsDir, statesDir}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -37,6 +48,10 @@ func NewStorage(dataDir string) (*Storage, error) {
 		dataDir:     dataDir,
 		clientsDir:  clientsDir,
 		requestsDir: requestsDir,
+		pathsDir:    pathsDir,
+		networkDir:  networkDir,
+		metricsDir:  metricsDir,
+		statesDir:   statesDir,
 		configFile:  configFile,
 	}, nil
 }
@@ -136,8 +151,55 @@ func (s *Storage) SaveNetworkRequest(clientID string, request shared.NetworkRequ
 	return os.WriteFile(filename, data, 0644)
 }
 
-// GetNetworkRequests gets network requests for a client
+// HasNetworkRequest reports whether a request with the same ID is already stored for the client.
+// Requests are filed by the day they started, and the neighbouring days are checked too since
+// clock skew correction can move a request across midnight.
+func (s *Storage) HasNetworkRequest(clientID string, request shared.NetworkRequest) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if request.ID == "" {
+		return false
+	}
+
+	clientDir := filepath.Join(s.requestsDir, clientID)
+	for _, day := range []time.Time{request.StartTime.AddDate(0, 0, -1), request.StartTime, request.StartTime.AddDate(0, 0, 1)} {
+		filename := filepath.Join(clientDir, day.Format("2006-01-02"), request.ID+".json")
+		if _, err := os.Stat(filename); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNetworkRequests gets the newest network requests for a client
 func (s *Storage) GetNetworkRequests(clientID string, limit int) ([]shared.NetworkRequest, error) {
+	return s.getNetworkRequests(clientID, limit, nil)
+}
+
+// GetNetworkRequestsByType gets network requests produced by a given probe type
+func (s *Storage) GetNetworkRequestsByType(clientID, probeType string, limit int) ([]shared.NetworkRequest, error) {
+	return s.getNetworkRequests(clientID, limit, func(request shared.NetworkRequest) bool {
+		return hasProbeType(request, probeType)
+	})
+}
+
+// GetNetworkRequestsMatching gets network requests for a client that pass the filter
+func (s *Storage) GetNetworkRequestsMatching(clientID string, limit int, filter func(shared.NetworkRequest) bool) ([]shared.NetworkRequest, error) {
+	return s.getNetworkRequests(clientID, limit, filter)
+}
+
+// hasProbeType reports whether a request was produced by a given probe type
+func hasProbeType(request shared.NetworkRequest, probeType string) bool {
+	// Results recorded before probe types existed are HTTP requests
+	if request.ProbeType == "" {
+		return probeType == shared.ProbeHTTP
+	}
+	return request.ProbeType == probeType
+}
+
+// getNetworkRequests gets the newest network requests for a client that pass the filter, newest first
+func (s *Storage) getNetworkRequests(clientID string, limit int, filter func(shared.NetworkRequest) bool) ([]shared.NetworkRequest, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -153,8 +215,8 @@ func (s *Storage) GetNetworkRequests(clientID string, limit int) ([]shared.Netwo
 		return nil, err
 	}
 
-	// Sort date directories in reverse order (newest first)
-	// This is a simplification; in a real app, parse dates and sort properly
+	// Date directories are named YYYY-MM-DD, so sorting their names in reverse visits the newest day first
+	sort.Slice(dateDirs, func(i, j int) bool { return dateDirs[i].Name() > dateDirs[j].Name() })
 	var allRequests []shared.NetworkRequest
 
 	// Process each date directory
@@ -170,7 +232,8 @@ func (s *Storage) GetNetworkRequests(clientID string, limit int) ([]shared.Netwo
 			continue
 		}
 
-		// Process each request file
+		// Files are named by request ID, so the day is read whole and ordered by start time
+		var dayRequests []shared.NetworkRequest
 		for _, file := range files {
 			if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
 				continue
@@ -188,11 +251,19 @@ func (s *Storage) GetNetworkRequests(clientID string, limit int) ([]shared.Netwo
 			if err := json.Unmarshal(data, &request); err != nil {
 				continue
 			}
+			if filter != nil && !filter(request) {
+				continue
+			}
 
-			allRequests = append(allRequests, request)
+			dayRequests = append(dayRequests, request)
+		}
+		sort.Slice(dayRequests, func(i, j int) bool { return dayRequests[i].StartTime.After(dayRequests[j].StartTime) })
+
+		for _, request := range dayRequests {
 			if len(allRequests) >= limit {
 				break
 			}
+			allRequests = append(allRequests, request)
 		}
 
 		if len(allRequests) >= limit {
@@ -203,13 +274,410 @@ func (s *Storage) GetNetworkRequests(clientID string, limit int) ([]shared.Netwo
 	return allRequests, nil
 }
 
+// GetLatestNetworkRequests gets the most recent request per target for every client, looking only
+// at the last days of history so the cost does not grow with the history kept
+func (s *Storage) GetLatestNetworkRequests(days int, filter func(shared.NetworkRequest) bool) (map[string][]shared.NetworkRequest, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	clientDirs, err := os.ReadDir(s.requestsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Date directories are named YYYY-MM-DD, so they compare as strings
+	firstDay := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+
+	latest := make(map[string][]shared.NetworkRequest)
+	for _, clientDir := range clientDirs {
+		if !clientDir.IsDir() {
+			continue
+		}
+
+		clientPath := filepath.Join(s.requestsDir, clientDir.Name())
+		dateDirs, err := os.ReadDir(clientPath)
+		if err != nil {
+			continue
+		}
+
+		// Keep the newest request seen for each target
+		byTarget := make(map[string]shared.NetworkRequest)
+		for _, dateDir := range dateDirs {
+			if !dateDir.IsDir() || dateDir.Name() < firstDay {
+				continue
+			}
+
+			datePath := filepath.Join(clientPath, dateDir.Name())
+			files, err := os.ReadDir(datePath)
+			if err != nil {
+				continue
+			}
+
+			for _, file := range files {
+				if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+					continue
+				}
+
+				data, err := os.ReadFile(filepath.Join(datePath, file.Name()))
+				if err != nil {
+					continue
+				}
+
+				var request shared.NetworkRequest
+				if err := json.Unmarshal(data, &request); err != nil {
+					continue
+				}
+				if filter != nil && !filter(request) {
+					continue
+				}
+
+				if existing, found := byTarget[request.TargetName]; !found || request.StartTime.After(existing.StartTime) {
+					byTarget[request.TargetName] = request
+				}
+			}
+		}
+
+		for _, request := range byTarget {
+			latest[clientDir.Name()] = append(latest[clientDir.Name()], request)
+		}
+	}
+
+	return latest, nil
+}
+
+// RecordPath stores the latest traced path for a client's target and reports whether it
+// differs from the previously stored one
+func (s *Storage) RecordPath(clientID, targetName string, path shared.PathTrace) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	clientDir := filepath.Join(s.pathsDir, clientID)
+	if err := os.MkdirAll(clientDir, 0755); err != nil {
+		return false, err
+	}
+
+	// Target names are user supplied, so escape them before using them as file names
+	filename := filepath.Join(clientDir, url.PathEscape(targetName)+".json")
+
+	changed := false
+	if data, err := os.ReadFile(filename); err == nil {
+		var previous shared.PathTrace
+		if err := json.Unmarshal(data, &previous); err == nil {
+			changed = pathChanged(previous.Hops, path.Hops)
+		}
+	}
+
+	path.Changed = changed
+	data, err := json.MarshalIndent(path, "", "  ")
+	if err != nil {
+		return changed, err
+	}
+
+	return changed, os.WriteFile(filename, data, 0644)
+}
+
+// pathChanged compares two traced paths hop by hop. Hops that did not reply in either
+// run are ignored so that rate-limited routers do not register as path changes.
+func pathChanged(previous, current []shared.PathHop) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+
+	for i := range current {
+		if previous[i].Address == "" || current[i].Address == "" {
+			continue
+		}
+		if previous[i].Address != current[i].Address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SaveNetworkEnvironment appends a client's network environment snapshot to its history,
+// recording in env.Changed what differs from the previous snapshot
+func (s *Storage) SaveNetworkEnvironment(clientID string, env shared.NetworkEnvironment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	clientDir := filepath.Join(s.networkDir, clientID)
+	if err := os.MkdirAll(clientDir, 0755); err != nil {
+		return err
+	}
+
+	// The latest snapshot is kept separately so it can be compared without scanning the history
+	latestFile := filepath.Join(clientDir, "latest.json")
+	env.Changed = nil
+	if data, err := os.ReadFile(latestFile); err == nil {
+		var previous shared.NetworkEnvironment
+		if err := json.Unmarshal(data, &previous); err == nil {
+			env.Changed = environmentChanges(previous, env)
+		}
+	}
+
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(latestFile, data, 0644); err != nil {
+		return err
+	}
+
+	// Snapshots arrive with every heartbeat, so each day's history is one file with a snapshot per line
+	return appendDailyLine(clientDir, env.CollectedAt, env)
+}
+
+// appendDailyLine appends value as a JSON line to the history file in dir for the day of at
+func appendDailyLine(dir string, at time.Time, value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	historyFile := filepath.Join(dir, at.Format("2006-01-02")+".jsonl")
+	file, err := os.OpenFile(historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// GetNetworkEnvironments gets a client's network environment history, newest first.
+// With changedOnly set only snapshots that differ from their predecessor are returned.
+func (s *Storage) GetNetworkEnvironments(clientID string, limit int, changedOnly bool) ([]shared.NetworkEnvironment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	environments := []shared.NetworkEnvironment{}
+
+	clientDir := filepath.Join(s.networkDir, clientID)
+	files, err := os.ReadDir(clientDir)
+	if os.IsNotExist(err) {
+		return environments, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// History files are named by date, so reading them in reverse goes from newest to oldest
+	for i := len(files) - 1; i >= 0 && len(environments) < limit; i-- {
+		if files[i].IsDir() || filepath.Ext(files[i].Name()) != ".jsonl" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(clientDir, files[i].Name()))
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		for j := len(lines) - 1; j >= 0 && len(environments) < limit; j-- {
+			var env shared.NetworkEnvironment
+			if err := json.Unmarshal([]byte(lines[j]), &env); err != nil {
+				continue
+			}
+			if changedOnly && len(env.Changed) == 0 {
+				continue
+			}
+			environments = append(environments, env)
+		}
+	}
+
+	return environments, nil
+}
+
+// SaveHostMetrics appends a client's resource usage sample to its time series
+func (s *Storage) SaveHostMetrics(clientID string, metrics shared.HostMetrics) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	clientDir := filepath.Join(s.metricsDir, clientID)
+	if err := os.MkdirAll(clientDir, 0755); err != nil {
+		return err
+	}
+
+	return appendDailyLine(clientDir, metrics.CollectedAt, metrics)
+}
+
+// GetHostMetrics gets a client's resource usage samples collected between from and to, oldest first.
+// When there are more than limit samples the most recent ones are returned.
+func (s *Storage) GetHostMetrics(clientID string, from, to time.Time, limit int) ([]shared.HostMetrics, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	samples := []shared.HostMetrics{}
+
+	clientDir := filepath.Join(s.metricsDir, clientID)
+	files, err := os.ReadDir(clientDir)
+	if os.IsNotExist(err) {
+		return samples, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the days outside the range, allowing for files named in a different time zone than from and to
+	firstDay := from.Add(-24 * time.Hour).Format("2006-01-02")
+	lastDay := to.Add(24 * time.Hour).Format("2006-01-02")
+
+	// Read from newest to oldest so the limit keeps the most recent samples
+	for i := len(files) - 1; i >= 0 && len(samples) < limit; i-- {
+		name := files[i].Name()
+		if files[i].IsDir() || filepath.Ext(name) != ".jsonl" {
+			continue
+		}
+		day := strings.TrimSuffix(name, ".jsonl")
+		if day < firstDay || day > lastDay {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(clientDir, name))
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		for j := len(lines) - 1; j >= 0 && len(samples) < limit; j-- {
+			var metrics shared.HostMetrics
+			if err := json.Unmarshal([]byte(lines[j]), &metrics); err != nil {
+				continue
+			}
+			if metrics.CollectedAt.Before(from) || metrics.CollectedAt.After(to) {
+				continue
+			}
+			samples = append(samples, metrics)
+		}
+	}
+
+	// Samples can arrive out of order after a reconnect, so sort rather than just reversing
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].CollectedAt.Before(samples[j].CollectedAt)
+	})
+	return samples, nil
+}
+
+// SaveNetworkState appends the outcome of a client's captive portal check to its history
+func (s *Storage) SaveNetworkState(clientID string, state shared.NetworkState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	clientDir := filepath.Join(s.statesDir, clientID)
+	if err := os.MkdirAll(clientDir, 0755); err != nil {
+		return err
+	}
+
+	return appendDailyLine(clientDir, state.CheckedAt, state)
+}
+
+// GetNetworkStates gets a client's captive portal check history, newest first
+func (s *Storage) GetNetworkStates(clientID string, limit int) ([]shared.NetworkState, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	states := []shared.NetworkState{}
+
+	clientDir := filepath.Join(s.statesDir, clientID)
+	files, err := os.ReadDir(clientDir)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(files) - 1; i >= 0 && len(states) < limit; i-- {
+		if files[i].IsDir() || filepath.Ext(files[i].Name()) != ".jsonl" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(clientDir, files[i].Name()))
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		for j := len(lines) - 1; j >= 0 && len(states) < limit; j-- {
+			var state shared.NetworkState
+			if err := json.Unmarshal([]byte(lines[j]), &state); err != nil {
+				continue
+			}
+			states = append(states, state)
+		}
+	}
+
+	return states, nil
+}
+
+// environmentChanges lists the parts of the network environment that differ between two snapshots.
+// Counters are expected to change and are ignored.
+func environmentChanges(previous, current shared.NetworkEnvironment) []string {
+	var changes []string
+
+	if !equalStrings(activeInterfaces(previous), activeInterfaces(current)) {
+		changes = append(changes, "interfaces")
+	}
+	if !equalStrings(interfaceAddresses(previous), interfaceAddresses(current)) {
+		changes = append(changes, "addresses")
+	}
+	if previous.DefaultGateway != current.DefaultGateway || previous.GatewayInterface != current.GatewayInterface {
+		changes = append(changes, "gateway")
+	}
+	if !equalStrings(previous.DNSServers, current.DNSServers) {
+		changes = append(changes, "dns")
+	}
+
+	return changes
+}
+
+// activeInterfaces returns the sorted names of the interfaces that are up, ignoring loopback
+func activeInterfaces(env shared.NetworkEnvironment) []string {
+	names := []string{}
+	for _, iface := range env.Interfaces {
+		if iface.Up && !iface.Loopback {
+			names = append(names, iface.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// interfaceAddresses returns the sorted addresses of the interfaces that are up, ignoring loopback
+func interfaceAddresses(env shared.NetworkEnvironment) []string {
+	addresses := []string{}
+	for _, iface := range env.Interfaces {
+		if iface.Up && !iface.Loopback {
+			addresses = append(addresses, iface.Addresses...)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// equalStrings reports whether two string slices hold the same values in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // GetServerConfig gets the server configuration
 func (s *Storage) GetServerConfig() (shared.ServerConfig, error) {
 	s.mutex.RLock()
-	defer s.mutex.RUnlock()
 
 	// Check if config file exists
 	if _, err := os.Stat(s.configFile); os.IsNotExist(err) {
+		// Saving takes the write lock, so the read lock is released first
+		s.mutex.RUnlock()
+
 		// Create default config
 		config := shared.ServerConfig{
 			MaxClients:     100,
@@ -225,6 +693,7 @@ func (s *Storage) GetServerConfig() (shared.ServerConfig, error) {
 		
 		return config, nil
 	}
+	defer s.mutex.RUnlock()
 
 	// Read config file
 	data, err := os.ReadFile(s.configFile)
@@ -1,9 +1,52 @@
 package shared
 
+import (
+	"time"
+)
+
 // ServerConfig represents the server configuration
 type ServerConfig struct {
-	MaxClients      int    `json:"maxClients"`
-	HistoryDays     int    `json:"historyDays"`
-	ListenAddress   string `json:"listenAddress"`
-	RefreshInterval int    `json:"refreshInterval"`
-}
\ No newline at end of file
+	MaxClients       int    `json:"maxClients"`
+	HistoryDays      int    `json:"historyDays"`
+	ListenAddress    string `json:"listenAddress"`
+	RefreshInterval  int    `json:"refreshInterval"`
+	CorrectClockSkew bool   `json:"correctClockSkew"` // shift stored request times by each client's clock skew
+}
+
+// ExpiringCertificate represents a certificate seen by a client that expires soon
+type ExpiringCertificate struct {
+	ClientID    string          `json:"clientId"`
+	TargetName  string          `json:"targetName"`
+	URL         string          `json:"url"`
+	ObservedAt  time.Time       `json:"observedAt"`
+	Certificate CertificateInfo `json:"certificate"`
+}
+
+// ProtocolStats summarises the HTTP results for one URL over one protocol
+type ProtocolStats struct {
+	URL            string  `json:"url"`
+	Protocol       string  `json:"protocol"`
+	Requests       int     `json:"requests"`
+	Failures       int     `json:"failures"`
+	AvgDNSTime     float64 `json:"avgDnsTime"`     // in milliseconds, over successful requests
+	AvgTCPTime     float64 `json:"avgTcpTime"`     // in milliseconds, over successful requests
+	AvgTLSTime     float64 `json:"avgTlsTime"`     // in milliseconds, over successful requests
+	AvgRequestTime float64 `json:"avgRequestTime"` // in milliseconds, over successful requests
+	AvgTotalTime   float64 `json:"avgTotalTime"`   // in milliseconds, over successful requests
+}
+
+// DualStackHealth summarises how a target behaves over IPv4 and IPv6 for one client
+type DualStackHealth struct {
+	TargetName string       `json:"targetName"`
+	IPv4       FamilyHealth `json:"ipv4"`
+	IPv6       FamilyHealth `json:"ipv6"`
+	Preferred  int          `json:"preferred"` // unforced probes of dual-stack hosts that connected over IPv6
+	Fallbacks  int          `json:"fallbacks"` // unforced probes of dual-stack hosts that fell back to IPv4
+}
+
+// FamilyHealth summarises the results of probes forced over one address family
+type FamilyHealth struct {
+	Requests     int     `json:"requests"`
+	Failures     int     `json:"failures"`
+	AvgTotalTime float64 `json:"avgTotalTime"` // in milliseconds, over successful requests
+}
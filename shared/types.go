@@ -29,6 +29,221 @@ type NetworkRequest struct {
 	Error         string    `json:"error"`
 	ErrorType     string    `json:"errorType"`
 	TargetName    string    `json:"targetName"`    // Name of the monitored target
+	ProbeType     string    `json:"probeType"`     // Kind of probe that produced the result
+	RemoteAddr    string    `json:"remoteAddr,omitempty"`
+	Protocol      string    `json:"protocol,omitempty"` // HTTP version negotiated with the server or forced by the target
+
+	// Probe specific details, set only when captured
+	Ping      *PingStats       `json:"ping,omitempty"`
+	DNS       *DNSResult       `json:"dns,omitempty"`
+	TLS       *TLSInfo         `json:"tls,omitempty"`
+	Assertion *AssertionResult `json:"assertion,omitempty"`
+	Redirects []RedirectHop    `json:"redirects,omitempty"` // every hop including the final response
+	Transfer  *TransferStats   `json:"transfer,omitempty"`
+	TCPInfo   *TCPInfo         `json:"tcpInfo,omitempty"` // Linux only
+	Steps     []StepResult     `json:"steps,omitempty"`   // transaction step breakdown
+	Path      *PathTrace       `json:"path,omitempty"`
+	Family    *FamilyInfo      `json:"family,omitempty"`
+	GRPC      *GRPCResult      `json:"grpc,omitempty"`
+	WebSocket *WebSocketResult `json:"webSocket,omitempty"`
+	Banner    *BannerResult    `json:"banner,omitempty"`
+	NTP       *NTPResult       `json:"ntp,omitempty"`
+	Proxy     *ProxyResult     `json:"proxy,omitempty"`
+
+	// Scope is gateway or resolver for the client's built-in first-hop probes, empty for configured targets
+	Scope string `json:"scope,omitempty"`
+
+	// Set by the server on failed results: local_network, resolver or upstream, judged from the latest first-hop probes
+	FailureScope string `json:"failureScope,omitempty"`
+
+	// NetworkState is the client's network state when the result was taken, set unless the network was open
+	NetworkState string `json:"networkState,omitempty"`
+
+	// Set by the server on successful results taken behind a captive portal or interception, which cannot be trusted
+	Suppressed bool `json:"suppressed,omitempty"`
+
+	// Set by the server when it shifted the timestamps onto its own clock
+	SkewCorrection int64 `json:"skewCorrection,omitempty"` // in milliseconds, subtracted from StartTime and EndTime
+}
+
+// ProxyResult represents the proxy an HTTP request was sent through
+type ProxyResult struct {
+	URL         string `json:"url"`                // password redacted
+	Source      string `json:"source"`             // manual, environment or pac
+	ConnectTime int64  `json:"connectTime"`        // until the proxy could carry the request, in milliseconds
+	Status      int    `json:"status,omitempty"`   // reply to the CONNECT request
+	FailedAt    string `json:"failedAt,omitempty"` // proxy or origin, set when the request failed
+}
+
+// NTPResult represents the reply of a time server
+type NTPResult struct {
+	Stratum     int     `json:"stratum"`
+	Leap        int     `json:"leap"` // 3 when the server clock is unsynchronized
+	ReferenceID string  `json:"referenceId,omitempty"`
+	Offset      float64 `json:"offset"` // local clock behind the server when positive, in milliseconds
+	Delay       float64 `json:"delay"`  // round trip excluding server processing, in milliseconds
+}
+
+// BannerResult represents the greeting and TLS upgrade of a mail or directory server
+type BannerResult struct {
+	Banner      string `json:"banner,omitempty"`      // first line of the greeting, or the bind result for LDAP
+	BannerTime  int64  `json:"bannerTime"`            // in milliseconds
+	StartTLS    bool   `json:"startTls"`              // the connection was upgraded with STARTTLS
+	FailedStage string `json:"failedStage,omitempty"` // connect, tls, banner or starttls
+}
+
+// WebSocketResult represents the outcome of a WebSocket probe
+type WebSocketResult struct {
+	HandshakeTime int64  `json:"handshakeTime"` // upgrade request to response, in milliseconds
+	Subprotocol   string `json:"subprotocol,omitempty"`
+	Reply         string `json:"reply,omitempty"` // last reply received, truncated to 1 KiB
+	ReplyTime     int64  `json:"replyTime"`       // in milliseconds
+	Matched       bool   `json:"matched"`         // a reply contained the expected text
+}
+
+// GRPCResult represents the outcome of a gRPC health check
+type GRPCResult struct {
+	Service     string `json:"service"`          // empty for the server as a whole
+	Status      string `json:"status,omitempty"` // SERVING, NOT_SERVING, UNKNOWN or SERVICE_UNKNOWN
+	ConnectTime int64  `json:"connectTime"`      // until the channel was ready, in milliseconds
+}
+
+// FamilyInfo represents the address families resolved for and used by a probe
+type FamilyInfo struct {
+	Requested    string `json:"requested,omitempty"` // family forced by the target
+	Used         string `json:"used,omitempty"`      // family of the address connected to
+	ResolvedIPv4 bool   `json:"resolvedIpv4"`
+	ResolvedIPv6 bool   `json:"resolvedIpv6"`
+	Fallback     bool   `json:"fallback"` // IPv6 was resolved but the connection was made over IPv4
+}
+
+// PathTrace represents the network path discovered by a trace probe
+type PathTrace struct {
+	Destination string    `json:"destination"`
+	Reached     bool      `json:"reached"`
+	Hops        []PathHop `json:"hops"`
+	Changed     bool      `json:"changed"` // set by the server when a complete trace differs from the previous one
+}
+
+// PathHop represents a single hop of a traced path
+type PathHop struct {
+	TTL     int     `json:"ttl"`
+	Address string  `json:"address,omitempty"` // empty when the hop did not reply
+	RTT     float64 `json:"rtt"`               // in milliseconds
+}
+
+// StepResult represents the outcome of one step of a transaction
+type StepResult struct {
+	Name        string           `json:"name"`
+	URL         string           `json:"url"`
+	Method      string           `json:"method"`
+	StatusCode  int              `json:"statusCode"`
+	DNSTime     int64            `json:"dnsTime"`     // in milliseconds
+	TCPTime     int64            `json:"tcpTime"`     // in milliseconds
+	TLSTime     int64            `json:"tlsTime"`     // in milliseconds
+	RequestTime int64            `json:"requestTime"` // in milliseconds
+	TotalTime   int64            `json:"totalTime"`   // in milliseconds
+	Error       string           `json:"error,omitempty"`
+	ErrorType   string           `json:"errorType,omitempty"`
+	Assertion   *AssertionResult `json:"assertion,omitempty"`
+	Extracted   []string         `json:"extracted,omitempty"` // names of the variables set by this step
+}
+
+// TCPInfo represents kernel statistics for a monitored TCP connection
+type TCPInfo struct {
+	RTT          uint32 `json:"rtt"`    // smoothed RTT, in microseconds
+	RTTVar       uint32 `json:"rttVar"` // in microseconds
+	MinRTT       uint32 `json:"minRtt"` // in microseconds
+	Retransmits  uint8  `json:"retransmits"` // unrecovered retransmits of the current segment
+	TotalRetrans uint32 `json:"totalRetrans"`
+	Lost         uint32 `json:"lost"`
+	SndCwnd      uint32 `json:"sndCwnd"` // congestion window, in segments
+	SndSsthresh  uint32 `json:"sndSsthresh"`
+	SndMSS       uint32 `json:"sndMss"` // in bytes
+	RcvMSS       uint32 `json:"rcvMss"` // in bytes
+	PMTU         uint32 `json:"pmtu"`   // in bytes
+}
+
+// TransferStats represents the download of a response body
+type TransferStats struct {
+	BytesTransferred int64   `json:"bytesTransferred"`
+	ContentLength    int64   `json:"contentLength"` // as declared by the server, -1 if unknown
+	ContentEncoding  string  `json:"contentEncoding,omitempty"`
+	Decompressed     bool    `json:"decompressed"` // bytes were counted after transparent decompression
+	Truncated        bool    `json:"truncated"`    // the body exceeded the download cap
+	TimeToLastByte   int64   `json:"timeToLastByte"` // in milliseconds
+	Throughput       float64 `json:"throughput"`     // in bytes per second
+}
+
+// RedirectHop represents a single request in a redirect chain
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	DNSTime    int64  `json:"dnsTime"`   // in milliseconds
+	TCPTime    int64  `json:"tcpTime"`   // in milliseconds
+	TLSTime    int64  `json:"tlsTime"`   // in milliseconds
+	TTFB       int64  `json:"ttfb"`      // time to first byte, in milliseconds
+	TotalTime  int64  `json:"totalTime"` // in milliseconds
+}
+
+// PingStats represents the outcome of a burst of echo requests
+type PingStats struct {
+	Method          string  `json:"method"` // "icmp" or "udp"
+	PacketsSent     int     `json:"packetsSent"`
+	PacketsReceived int     `json:"packetsReceived"`
+	PacketLoss      float64 `json:"packetLoss"` // percentage
+	MinRTT          float64 `json:"minRtt"`     // in milliseconds
+	AvgRTT          float64 `json:"avgRtt"`     // in milliseconds
+	MaxRTT          float64 `json:"maxRtt"`     // in milliseconds
+	StdDevRTT       float64 `json:"stdDevRtt"`  // in milliseconds
+	Jitter          float64 `json:"jitter"`     // in milliseconds
+}
+
+// DNSResult represents the outcome of a DNS probe
+type DNSResult struct {
+	Name       string      `json:"name"`
+	RecordType string      `json:"recordType"`
+	Resolver   string      `json:"resolver"`
+	RCode      string      `json:"rcode"`
+	Answers    []DNSAnswer `json:"answers"`
+	Expected   []string    `json:"expected,omitempty"`
+	Matched    bool        `json:"matched"`
+}
+
+// DNSAnswer represents a single resource record in a DNS response
+type DNSAnswer struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   uint32 `json:"ttl"` // in seconds
+}
+
+// TLSInfo represents the negotiated parameters of a TLS connection
+type TLSInfo struct {
+	Version      string            `json:"version"`
+	CipherSuite  string            `json:"cipherSuite"`
+	ALPN         string            `json:"alpn,omitempty"`
+	ServerName   string            `json:"serverName,omitempty"`
+	Certificates []CertificateInfo `json:"certificates"` // leaf first
+	DaysToExpiry int               `json:"daysToExpiry"` // of the leaf certificate
+}
+
+// CertificateInfo represents a certificate presented by a peer
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SANs         []string  `json:"sans,omitempty"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	DaysToExpiry int       `json:"daysToExpiry"`
+}
+
+// AssertionResult represents the outcome of evaluating a target's assertions
+type AssertionResult struct {
+	Passed  bool   `json:"passed"`
+	Failure string `json:"failure,omitempty"` // first failing assertion
 }
 
 // ClientInfo represents information about a client
@@ -42,6 +257,62 @@ type ClientInfo struct {
 	LastSeen     time.Time    `json:"lastSeen"`
 	Version      string       `json:"version"`
 	OSInfo       string       `json:"osInfo"`
+	ClockSkew    int64        `json:"clockSkew,omitempty"` // client clock ahead of the server, in milliseconds, set by the server
+	NetworkState string       `json:"networkState,omitempty"` // latest captive portal check, set by the server
+	DroppedResults int64      `json:"droppedResults,omitempty"` // results the client discarded since it started
+}
+
+// NetworkState represents the outcome of a client's captive portal check
+type NetworkState struct {
+	CheckedAt   time.Time `json:"checkedAt"`
+	State       string    `json:"state"` // open, captive_portal, tampered, intercepted or offline
+	URL         string    `json:"url"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	RedirectURL string    `json:"redirectUrl,omitempty"`
+	Via         string    `json:"via,omitempty"`    // Via header added by a proxy on the path
+	Issuer      string    `json:"issuer,omitempty"` // certificate issuer, for HTTPS check URLs
+	Detail      string    `json:"detail,omitempty"` // what gave the network away
+}
+
+// NetworkEnvironment represents a snapshot of a client's local network configuration
+type NetworkEnvironment struct {
+	CollectedAt      time.Time          `json:"collectedAt"`
+	Interfaces       []NetworkInterface `json:"interfaces"`
+	DefaultGateway   string             `json:"defaultGateway,omitempty"`
+	GatewayInterface string             `json:"gatewayInterface,omitempty"`
+	DNSServers       []string           `json:"dnsServers,omitempty"`
+	Changed          []string           `json:"changed,omitempty"` // set by the server to what differs from the previous snapshot
+}
+
+// NetworkInterface represents a network interface and its counters since boot
+type NetworkInterface struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac,omitempty"`
+	MTU       int      `json:"mtu"`
+	Up        bool     `json:"up"`
+	Loopback  bool     `json:"loopback"`
+	Addresses []string `json:"addresses,omitempty"` // in CIDR notation
+	BytesSent uint64   `json:"bytesSent"`
+	BytesRecv uint64   `json:"bytesRecv"`
+	ErrorsIn  uint64   `json:"errorsIn"`
+	ErrorsOut uint64   `json:"errorsOut"`
+	DropsIn   uint64   `json:"dropsIn"`
+	DropsOut  uint64   `json:"dropsOut"`
+}
+
+// HostMetrics represents the resource usage of a client machine
+type HostMetrics struct {
+	CollectedAt   time.Time `json:"collectedAt"`
+	CPUPercent    float64   `json:"cpuPercent"` // across all cores since the previous sample
+	CPUCount      int       `json:"cpuCount"`   // logical cores
+	MemoryTotal   uint64    `json:"memoryTotal"` // in bytes
+	MemoryUsed    uint64    `json:"memoryUsed"`  // in bytes
+	MemoryPercent float64   `json:"memoryPercent"`
+	SwapPercent   float64   `json:"swapPercent"`
+	Load1         float64   `json:"load1"` // load averages, zero where the platform has none
+	Load5         float64   `json:"load5"`
+	Load15        float64   `json:"load15"`
+	Processes     int       `json:"processes"`
 }
 
 // ClientMessage represents a message sent from client to server
@@ -50,6 +321,12 @@ type ClientMessage struct {
 	ClientID  string          `json:"clientId"`
 	Timestamp time.Time       `json:"timestamp"`
 	Data      interface{}     `json:"data"`
+	Sequence  uint64          `json:"sequence,omitempty"` // numbers the client's messages so the server can acknowledge them
+}
+
+// MessageAck represents the server's acknowledgement that a client message was persisted
+type MessageAck struct {
+	Sequence uint64 `json:"sequence"`
 }
 
 // ServerMessage represents a message sent from server to client
@@ -67,12 +344,163 @@ type ConfigFile struct {
 	LastEdit time.Time `json:"lastEdit"`
 }
 
-// Target represents a website to monitor
+// ProbeType constants
+const (
+	ProbeHTTP        = "http"
+	ProbeTCP         = "tcp"
+	ProbePing        = "ping"
+	ProbeDNS         = "dns"
+	ProbeTransaction = "transaction"
+	ProbeTrace       = "trace"
+	ProbeGRPC        = "grpc"
+	ProbeWebSocket   = "websocket"
+	ProbeSMTP        = "smtp"
+	ProbeIMAP        = "imap"
+	ProbeLDAP        = "ldap"
+	ProbeNTP         = "ntp"
+)
+
+// HTTP protocol constants
+const (
+	ProtocolHTTP1 = "http1.1"
+	ProtocolHTTP2 = "http2"
+	ProtocolHTTP3 = "http3"
+)
+
+// Proxy mode constants
+const (
+	ProxyDirect      = "direct"
+	ProxyManual      = "manual"
+	ProxyEnvironment = "environment"
+	ProxyPAC         = "pac"
+)
+
+// Network state constants
+const (
+	NetworkStateOpen          = "open"
+	NetworkStateCaptivePortal = "captive_portal" // the check was redirected or answered by a login page
+	NetworkStateTampered      = "tampered"       // the known content was altered or replaced
+	NetworkStateIntercepted   = "intercepted"    // an untrusted or unexpected certificate was presented
+	NetworkStateOffline       = "offline"        // the check URL could not be reached
+)
+
+// First-hop scope constants
+const (
+	ScopeGateway  = "gateway"
+	ScopeResolver = "resolver"
+)
+
+// Failure scope constants
+const (
+	FailureLocalNetwork = "local_network"
+	FailureResolver     = "resolver"
+	FailureUpstream     = "upstream"
+)
+
+// Address family constants
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyDual = "dual"
+)
+
+// Target represents a website or service to monitor
 type Target struct {
 	Name     string `json:"name"`
-	URL      string `json:"url"`
-	Interval int    `json:"interval"` // in seconds
+	Type     string `json:"type,omitempty"` // probe kind, defaults to "http"
+	URL      string `json:"url,omitempty"`
+	Address  string `json:"address,omitempty"` // host:port for non-HTTP probes
+	Interval int    `json:"interval"`          // in seconds
+	Timeout  int    `json:"timeout,omitempty"` // in milliseconds, defaults to 30s
 	Enabled  bool   `json:"enabled"`
+
+	// AddressFamily forces ipv4 or ipv6, or probes over both separately when dual
+	AddressFamily string `json:"addressFamily,omitempty"`
+
+	// TLS adjusts certificate trust and client authentication for HTTPS, transaction, WebSocket, gRPC, SMTP, IMAP and LDAP probes
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// HTTP probe settings
+	Method          string            `json:"method,omitempty"` // defaults to GET
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body,omitempty"`
+	FollowRedirects *bool             `json:"followRedirects,omitempty"` // defaults to true
+	ReadBody        bool              `json:"readBody,omitempty"`     // download the body to measure throughput
+	MaxBodyBytes    int64             `json:"maxBodyBytes,omitempty"` // download cap, defaults to 10 MiB
+	Assertions      *Assertions       `json:"assertions,omitempty"`
+	Protocol        string            `json:"protocol,omitempty"` // http1.1, http2 or http3, negotiated when empty
+	Proxy           *ProxyConfig      `json:"proxy,omitempty"`    // overrides the client proxy, also used by transactions
+
+	// Transaction probe settings
+	Steps []TransactionStep `json:"steps,omitempty"`
+
+	// Ping probe settings
+	Count int `json:"count,omitempty"` // echo requests per burst
+
+	// gRPC probe settings
+	Service string `json:"service,omitempty"` // health service name, empty checks the whole server
+	Secure  bool   `json:"secure,omitempty"`  // dial with TLS instead of plaintext, also used by SMTP, IMAP and LDAP
+
+	// SMTP, IMAP and LDAP probe settings
+	StartTLS bool `json:"startTls,omitempty"` // upgrade a plaintext connection and capture the certificate
+
+	// WebSocket probe settings, URL holds the ws:// or wss:// endpoint
+	Message       string `json:"message,omitempty"`       // sent once connected
+	ExpectedReply string `json:"expectedReply,omitempty"` // text a reply must contain, any reply matches when empty
+	ReplyTimeout  int    `json:"replyTimeout,omitempty"`  // in milliseconds, defaults to the probe timeout
+
+	// NTP probe settings
+	MaxOffset int64 `json:"maxOffset,omitempty"` // in milliseconds, larger clock offsets fail the probe
+
+	// Trace probe settings
+	MaxHops int `json:"maxHops,omitempty"` // defaults to 30
+
+	// DNS probe settings, Address holds the name to query
+	RecordType      string   `json:"recordType,omitempty"` // A, AAAA, CNAME, MX, TXT or SRV
+	Resolver        string   `json:"resolver,omitempty"`   // host:port, defaults to the system resolver
+	ExpectedAnswers []string `json:"expectedAnswers,omitempty"`
+
+	// Scope is copied to the results of the built-in first-hop targets, it cannot be configured
+	Scope string `json:"-"`
+}
+
+// Assertions represents the checks an HTTP response must pass to count as up
+type Assertions struct {
+	StatusCodes  []string               `json:"statusCodes,omitempty"` // "200", "2xx" or "200-299"
+	BodyContains string                 `json:"bodyContains,omitempty"`
+	BodyRegex    string                 `json:"bodyRegex,omitempty"`
+	JSONPath     map[string]interface{} `json:"jsonPath,omitempty"` // dotted path to expected value
+	Headers      map[string]string      `json:"headers,omitempty"`  // empty value only requires presence
+	MaxTotalTime int64                  `json:"maxTotalTime,omitempty"` // in milliseconds
+}
+
+// TLSConfig represents the TLS settings of a target
+type TLSConfig struct {
+	CAFile     string `json:"caFile,omitempty"`     // PEM bundle trusted instead of the system roots
+	CertFile   string `json:"certFile,omitempty"`   // PEM client certificate, sent when the server asks for one
+	KeyFile    string `json:"keyFile,omitempty"`    // PEM private key of the client certificate
+	ServerName string `json:"serverName,omitempty"` // sent in SNI and verified instead of the target host
+	MinVersion string `json:"minVersion,omitempty"` // 1.0, 1.1, 1.2 or 1.3, defaults to 1.2
+	Insecure   bool   `json:"insecure,omitempty"`   // skip certificate verification
+}
+
+// ProxyConfig represents how HTTP requests reach their targets
+type ProxyConfig struct {
+	Mode string `json:"mode"`          // direct, manual, environment or pac
+	URL  string `json:"url,omitempty"` // http:// or socks5:// proxy for manual, script location for pac
+}
+
+// TransactionStep represents one HTTP request of a transaction target.
+// URL, header and body values may reference earlier extractions as {{name}}.
+type TransactionStep struct {
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body,omitempty"`
+	FollowRedirects *bool             `json:"followRedirects,omitempty"`
+	Extract         map[string]string `json:"extract,omitempty"` // name to "header:Name", "cookie:Name" or "json:path"
+	Assertions      *Assertions       `json:"assertions,omitempty"`
 }
 
 // ClientConfig represents the client configuration
@@ -81,6 +509,34 @@ type ClientConfig struct {
 	ClientName    string   `json:"clientName"`
 	Targets       []Target `json:"targets"`
 	LogLevel      string   `json:"logLevel"`
+
+	// Proxy is used by HTTP targets that do not set their own
+	Proxy *ProxyConfig `json:"proxy,omitempty"`
+
+	// MetricsInterval is how often host resource usage is reported, in seconds, defaulting to 30
+	MetricsInterval int `json:"metricsInterval,omitempty"`
+
+	// FirstHopInterval is how often the default gateway and DNS resolvers are probed, in seconds, defaulting to 30
+	FirstHopInterval int  `json:"firstHopInterval,omitempty"`
+	DisableFirstHop  bool `json:"disableFirstHop,omitempty"`
+
+	// PortalCheck adjusts the captive portal check, which runs with the defaults when unset
+	PortalCheck *PortalCheckConfig `json:"portalCheck,omitempty"`
+
+	// Results that cannot be sent are spooled to disk until the server is reachable again.
+	// The oldest are dropped beyond SpoolMaxSize bytes (50 MiB by default) or SpoolMaxAge hours (a week by default).
+	SpoolMaxSize int64 `json:"spoolMaxSize,omitempty"`
+	SpoolMaxAge  int   `json:"spoolMaxAge,omitempty"`
+}
+
+// PortalCheckConfig represents how a client checks for captive portals and interception
+type PortalCheckConfig struct {
+	URL            string `json:"url,omitempty"`            // known-content URL, defaults to http://connectivitycheck.gstatic.com/generate_204
+	ExpectedStatus int    `json:"expectedStatus,omitempty"` // defaults to 204, or 200 when expectedBody is set
+	ExpectedBody   string `json:"expectedBody,omitempty"`   // exact content, ignoring surrounding whitespace
+	ExpectedIssuer string `json:"expectedIssuer,omitempty"` // for HTTPS URLs, text the certificate issuer must contain
+	Interval       int    `json:"interval,omitempty"`       // in seconds, defaults to 60
+	Disabled       bool   `json:"disabled,omitempty"`
 }
 
 // MessageType constants
@@ -96,4 +552,8 @@ const (
 	TypeClientDisconnect   = "client_disconnect"
 	TypeClientsList        = "clients_list"
 	TypeNetworkRequestList = "network_request_list"
+	TypeNetworkEnvironment = "network_environment"
+	TypeHostMetrics        = "host_metrics"
+	TypeNetworkState       = "network_state"
+	TypeAck                = "ack"
 )
\ No newline at end of file
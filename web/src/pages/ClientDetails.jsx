@@ -23,6 +23,7 @@ import DialogContent from '@mui/material/DialogContent';
 import DialogTitle from '@mui/material/DialogTitle';
 import { LineChart } from '@mui/x-charts/LineChart';
 import { fetchClient, fetchClientRequests, getClientConfigFile, updateClientConfigFile } from '../services/api';
+import { succeeded, isSuppressed } from '../services/results';
 import { formatDistanceToNow, format } from 'date-fns';
 import { 
   Laptop, 
@@ -150,26 +151,28 @@ function ClientDetails() {
     setConfigFile({ ...configFile, content: event.target.value });
   };
 
-  // Calculate statistics
+  // Calculate statistics. Suppressed results succeeded behind a captive portal or interception,
+  // so they count towards neither successes nor the success rate
   const stats = {
     totalRequests: requests.length,
-    successRequests: requests.filter(r => !r.error && r.statusCode < 400).length,
-    errorRequests: requests.filter(r => r.error || r.statusCode >= 400).length,
+    suppressedRequests: requests.filter(isSuppressed).length,
+    successRequests: requests.filter(r => succeeded(r) && !isSuppressed(r)).length,
+    errorRequests: requests.filter(r => !succeeded(r)).length,
     avgResponseTime: requests.length > 0 
-      ? Math.round(requests.filter(r => !r.error).reduce((sum, r) => sum + r.totalTime, 0) / 
-                  requests.filter(r => !r.error).length) 
+      ? Math.round(requests.filter(r => !r.error && !isSuppressed(r)).reduce((sum, r) => sum + r.totalTime, 0) / 
+                  requests.filter(r => !r.error && !isSuppressed(r)).length) 
       : 0,
     avgDnsTime: requests.length > 0 
-      ? Math.round(requests.filter(r => !r.error).reduce((sum, r) => sum + r.dnsTime, 0) / 
-                  requests.filter(r => !r.error).length) 
+      ? Math.round(requests.filter(r => !r.error && !isSuppressed(r)).reduce((sum, r) => sum + r.dnsTime, 0) / 
+                  requests.filter(r => !r.error && !isSuppressed(r)).length) 
       : 0,
     avgTcpTime: requests.length > 0 
-      ? Math.round(requests.filter(r => !r.error).reduce((sum, r) => sum + r.tcpTime, 0) / 
-                  requests.filter(r => !r.error).length) 
+      ? Math.round(requests.filter(r => !r.error && !isSuppressed(r)).reduce((sum, r) => sum + r.tcpTime, 0) / 
+                  requests.filter(r => !r.error && !isSuppressed(r)).length) 
       : 0,
     avgTlsTime: requests.length > 0 
-      ? Math.round(requests.filter(r => !r.error).reduce((sum, r) => sum + r.tlsTime, 0) / 
-                  requests.filter(r => !r.error).length) 
+      ? Math.round(requests.filter(r => !r.error && !isSuppressed(r)).reduce((sum, r) => sum + r.tlsTime, 0) / 
+                  requests.filter(r => !r.error && !isSuppressed(r)).length) 
       : 0
   };
 
@@ -309,6 +312,14 @@ function ClientDetails() {
                       size="small" 
                       color="error" 
                     />
+                    {stats.suppressedRequests > 0 && (
+                      <Chip 
+                        label={`${stats.suppressedRequests} Suppressed`} 
+                        size="small" 
+                        color="warning" 
+                        sx={{ ml: 1 }} 
+                      />
+                    )}
                   </Box>
                 </CardContent>
               </Card>
@@ -355,8 +366,8 @@ function ClientDetails() {
                     </Typography>
                   </Box>
                   <Typography variant="h4" component="div">
-                    {stats.totalRequests > 0 
-                      ? Math.round((stats.successRequests / stats.totalRequests) * 100) 
+                    {stats.totalRequests - stats.suppressedRequests > 0 
+                      ? Math.round((stats.successRequests / (stats.totalRequests - stats.suppressedRequests)) * 100) 
                       : 0}%
                   </Typography>
                   <Typography variant="body2" color="text.secondary" mt={1}>
@@ -497,6 +508,14 @@ function ClientDetails() {
                             size="small"
                             color="error"
                           />
+                        ) : request.assertion && !request.assertion.passed ? (
+                          <Chip 
+                            icon={<XCircle size={14} />}
+                            label={`${request.statusCode} Assertion failed`} 
+                            title={request.assertion.failure}
+                            size="small"
+                            color="warning"
+                          />
                         ) : (
                           <Chip 
                             icon={<CheckCircle2 size={14} />}
@@ -505,6 +524,16 @@ function ClientDetails() {
                             color={request.statusCode < 400 ? 'success' : 'error'}
                           />
                         )}
+                        {isSuppressed(request) && (
+                          <Chip 
+                            label="suppressed (captive portal/intercepted)" 
+                            title={request.networkState}
+                            size="small"
+                            color="warning"
+                            variant="outlined"
+                            sx={{ ml: 1 }}
+                          />
+                        )}
                       </TableCell>
                       <TableCell>
                         {formatDistanceToNow(new Date(request.startTime), { addSuffix: true })}
@@ -573,6 +602,14 @@ function ClientDetails() {
                           size="small"
                           color="error"
                         />
+                      ) : request.assertion && !request.assertion.passed ? (
+                        <Chip 
+                          icon={<XCircle size={14} />}
+                          label={`${request.statusCode} Assertion failed`} 
+                          title={request.assertion.failure}
+                          size="small"
+                          color="warning"
+                        />
                       ) : (
                         <Chip 
                           icon={<CheckCircle2 size={14} />}
@@ -581,6 +618,16 @@ function ClientDetails() {
                           color={request.statusCode < 400 ? 'success' : 'error'}
                         />
                       )}
+                      {isSuppressed(request) && (
+                        <Chip 
+                          label="suppressed (captive portal/intercepted)" 
+                          title={request.networkState}
+                          size="small"
+                          color="warning"
+                          variant="outlined"
+                          sx={{ ml: 1 }}
+                        />
+                      )}
                     </TableCell>
                     <TableCell>
                       {format(new Date(request.startTime), 'yyyy-MM-dd HH:mm:ss')}
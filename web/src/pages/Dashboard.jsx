@@ -24,6 +24,7 @@ import {
   ArrowRight
 } from 'lucide-react';
 import { fetchClients, fetchClientRequests } from '../services/api';
+import { isSuppressed } from '../services/results';
 import { formatDistanceToNow } from 'date-fns';
 
 function Dashboard() {
@@ -62,10 +63,11 @@ function Dashboard() {
         allRequests.sort((a, b) => new Date(b.startTime) - new Date(a.startTime));
         setRecentRequests(allRequests.slice(0, 10));
         
-        // Calculate success rate
+        // Calculate success rate, leaving out successes suppressed behind a captive portal or interception
         const totalReqs = allRequests.length;
         const errorReqs = allRequests.filter(r => r.error).length;
-        const successRate = totalReqs > 0 ? Math.round(((totalReqs - errorReqs) / totalReqs) * 100) : 100;
+        const countedReqs = totalReqs - allRequests.filter(isSuppressed).length;
+        const successRate = countedReqs > 0 ? Math.round(((countedReqs - errorReqs) / countedReqs) * 100) : 100;
         
         setStats({
           totalClients: clientsData.length,
@@ -393,6 +395,16 @@ function Dashboard() {
                         color={request.statusCode < 400 ? 'success' : 'error'}
                       />
                     )}
+                    {isSuppressed(request) && (
+                      <Chip 
+                        label="suppressed (captive portal/intercepted)" 
+                        title={request.networkState}
+                        size="small"
+                        color="warning"
+                        variant="outlined"
+                        sx={{ ml: 1 }}
+                      />
+                    )}
                   </TableCell>
                   <TableCell>
                     {formatDistanceToNow(new Date(request.startTime), { addSuffix: true })}
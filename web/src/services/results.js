@@ -0,0 +1,8 @@
+// Result predicates shared by the pages, matching those the server uses
+
+// A result succeeded when it has no error, no HTTP error status and no failed assertion
+export const succeeded = (request) =>
+  !request.error && request.statusCode < 400 && (!request.assertion || request.assertion.passed);
+
+// A suppressed result succeeded behind a captive portal or interception, so the answer may not be the target's
+export const isSuppressed = (request) => request.suppressed && succeeded(request);